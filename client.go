@@ -7,6 +7,7 @@ package grpc_prometheus
 
 import (
 	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
 )
 
 var (
@@ -23,10 +24,7 @@ var (
 )
 
 func init() {
-	prom.MustRegister(DefaultClientMetrics.clientStartedCounter)
-	prom.MustRegister(DefaultClientMetrics.clientHandledCounter)
-	prom.MustRegister(DefaultClientMetrics.clientStreamMsgReceived)
-	prom.MustRegister(DefaultClientMetrics.clientStreamMsgSent)
+	DefaultClientMetrics.MustRegister(prom.DefaultRegisterer)
 }
 
 // EnableClientHandlingTimeHistogram turns on recording of handling time of
@@ -35,7 +33,6 @@ func init() {
 // default Prometheus metrics registry.
 func EnableClientHandlingTimeHistogram(opts ...HistogramOption) {
 	DefaultClientMetrics.EnableClientHandlingTimeHistogram(opts...)
-	prom.Register(DefaultClientMetrics.clientHandledHistogram)
 }
 
 // EnableClientStreamReceiveTimeHistogram turns on recording of
@@ -44,7 +41,6 @@ func EnableClientHandlingTimeHistogram(opts ...HistogramOption) {
 // default Prometheus metrics registry.
 func EnableClientStreamReceiveTimeHistogram(opts ...HistogramOption) {
 	DefaultClientMetrics.EnableClientStreamReceiveTimeHistogram(opts...)
-	prom.Register(DefaultClientMetrics.clientStreamRecvHistogram)
 }
 
 // EnableClientStreamSendTimeHistogram turns on recording of
@@ -53,5 +49,19 @@ func EnableClientStreamReceiveTimeHistogram(opts ...HistogramOption) {
 // default Prometheus metrics registry.
 func EnableClientStreamSendTimeHistogram(opts ...HistogramOption) {
 	DefaultClientMetrics.EnableClientStreamSendTimeHistogram(opts...)
-	prom.Register(DefaultClientMetrics.clientStreamSendHistogram)
+}
+
+// EnableClientHandledCodeClassCounter turns on counting of RPCs completed by
+// the client by the class of their code, with the given overrides of
+// DefaultCodeClasses applied. This function acts on the DefaultClientMetrics
+// variable and the default Prometheus metrics registry.
+func EnableClientHandledCodeClassCounter(overrides map[codes.Code]string) {
+	DefaultClientMetrics.EnableClientHandledCodeClassCounter(overrides)
+}
+
+// AddClientCodeResolvers adds resolvers used to determine the code reported
+// for errors returned by invokers and client streams. This function acts on
+// the DefaultClientMetrics variable.
+func AddClientCodeResolvers(resolvers ...CodeResolver) {
+	DefaultClientMetrics.AddClientCodeResolvers(resolvers...)
 }
@@ -0,0 +1,86 @@
+package grpc_prometheus
+
+import (
+	"context"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// connectivityStates are the states of a grpc.ClientConn, initialized to 0
+// for each watched target.
+var connectivityStates = []connectivity.State{
+	connectivity.Idle,
+	connectivity.Connecting,
+	connectivity.Ready,
+	connectivity.TransientFailure,
+	connectivity.Shutdown,
+}
+
+// ClientConnMetrics represents a collection of connectivity state metrics of
+// gRPC client connections to be registered on a Prometheus metrics registry.
+// Connections are added with Watch.
+type ClientConnMetrics struct {
+	clientConnState            *prom.GaugeVec
+	clientConnTransitionsCount *prom.CounterVec
+}
+
+// NewClientConnMetrics returns a ClientConnMetrics object. The counter
+// options are applied to all of its metrics, including the gauge.
+func NewClientConnMetrics(counterOpts ...CounterOption) *ClientConnMetrics {
+	opts := counterOptions(counterOpts)
+	return &ClientConnMetrics{
+		clientConnState: prom.NewGaugeVec(
+			prom.GaugeOpts(opts.apply(prom.CounterOpts{
+				Name: "grpc_client_connection_state",
+				Help: "Number of watched client connections to the target in each connectivity state.",
+			})), []string{"target", "state"}),
+		clientConnTransitionsCount: prom.NewCounterVec(
+			opts.apply(prom.CounterOpts{
+				Name: "grpc_client_connection_state_transitions_total",
+				Help: "Total number of transitions of watched client connections to the target into each connectivity state.",
+			}), []string{"target", "state"}),
+	}
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once
+// the last descriptor has been sent.
+func (m *ClientConnMetrics) Describe(ch chan<- *prom.Desc) {
+	m.clientConnState.Describe(ch)
+	m.clientConnTransitionsCount.Describe(ch)
+}
+
+// Collect is called by the Prometheus registry when collecting
+// metrics. The implementation sends each collected metric via the
+// provided channel and returns once the last metric has been sent.
+func (m *ClientConnMetrics) Collect(ch chan<- prom.Metric) {
+	m.clientConnState.Collect(ch)
+	m.clientConnTransitionsCount.Collect(ch)
+}
+
+// Watch records the connectivity state of conn, labelled with its target,
+// until ctx is done or conn is closed. It returns immediately, watching in a
+// goroutine. The state gauge counts the watched connections to the target in
+// each state, so it is 0 or 1 per state for a single connection; connections
+// are no longer counted once they stop being watched.
+func (m *ClientConnMetrics) Watch(ctx context.Context, conn *grpc.ClientConn) {
+	target := conn.Target()
+	for _, state := range connectivityStates {
+		m.clientConnState.WithLabelValues(target, state.String())
+		m.clientConnTransitionsCount.WithLabelValues(target, state.String())
+	}
+	state := conn.GetState()
+	m.clientConnState.WithLabelValues(target, state.String()).Inc()
+	go func() {
+		for state != connectivity.Shutdown && conn.WaitForStateChange(ctx, state) {
+			next := conn.GetState()
+			m.clientConnState.WithLabelValues(target, state.String()).Dec()
+			m.clientConnState.WithLabelValues(target, next.String()).Inc()
+			m.clientConnTransitionsCount.WithLabelValues(target, next.String()).Inc()
+			state = next
+		}
+		m.clientConnState.WithLabelValues(target, state.String()).Dec()
+	}()
+}
@@ -0,0 +1,50 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// client connection metrics must satisfy the Collector interface
+var _ prometheus.Collector = NewClientConnMetrics()
+
+func TestClientConnMetrics(t *testing.T) {
+	connMetrics := NewClientConnMetrics()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "must be able to allocate a port for serverListener")
+	server := grpc.NewServer()
+	pb_testproto.RegisterTestServiceServer(server, &testService{t: t})
+	go server.Serve(lis)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+
+	target := lis.Addr().String()
+	clientConn, err := grpc.Dial(target, grpc.WithInsecure())
+	require.NoError(t, err, "must not error on client Dial")
+	connMetrics.Watch(ctx, clientConn)
+	require.Equal(t, 5, countSeries(connMetrics.clientConnState), "all states must be initialized")
+	_, err = pb_testproto.NewTestServiceClient(clientConn).PingEmpty(ctx, &pb_testproto.Empty{})
+	require.NoError(t, err)
+
+	requireValueWithRetry(ctx, t, 1, connMetrics.clientConnState.WithLabelValues(target, "READY"))
+	requireValueWithRetry(ctx, t, 1, connMetrics.clientConnTransitionsCount.WithLabelValues(target, "READY"))
+
+	server.Stop()
+	requireValueWithRetry(ctx, t, 0, connMetrics.clientConnState.WithLabelValues(target, "READY"))
+	requireValueWithRetry(ctx, t, 1, connMetrics.clientConnTransitionsCount.WithLabelValues(target, "TRANSIENT_FAILURE"))
+
+	clientConn.Close()
+	requireValueWithRetry(ctx, t, 1, connMetrics.clientConnTransitionsCount.WithLabelValues(target, "SHUTDOWN"))
+	for _, state := range connectivityStates {
+		requireValueWithRetry(ctx, t, 0, connMetrics.clientConnState.WithLabelValues(target, state.String()))
+	}
+}
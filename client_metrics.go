@@ -3,11 +3,14 @@ package grpc_prometheus
 import (
 	"context"
 	"io"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	prom "github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/metadata"
 )
 
 // ClientMetrics represents a collection of metrics to be registered on a
@@ -18,17 +21,49 @@ type ClientMetrics struct {
 	clientStreamMsgReceived *prom.CounterVec
 	clientStreamMsgSent     *prom.CounterVec
 
-	clientHandledHistogramEnabled bool
-	clientHandledHistogramOpts    prom.HistogramOpts
-	clientHandledHistogram        *prom.HistogramVec
+	clientHandledCodeClassCounter *prom.CounterVec
 
-	clientStreamRecvHistogramEnabled bool
-	clientStreamRecvHistogramOpts    prom.HistogramOpts
-	clientStreamRecvHistogram        *prom.HistogramVec
+	// mu serializes updates of cfg, which holds a *clientConfig, and of
+	// registerers, the registries the metrics were registered with by
+	// Register.
+	mu          sync.Mutex
+	cfg         atomic.Value
+	registerers []prom.Registerer
 
-	clientStreamSendHistogramEnabled bool
-	clientStreamSendHistogramOpts    prom.HistogramOpts
-	clientStreamSendHistogram        *prom.HistogramVec
+	// unchecked is set when Describe sends no descriptors.
+	unchecked bool
+	// families holds the families of the vectors.
+	families metricFamilies
+}
+
+// clientConfig is the configuration of ClientMetrics read by the
+// interceptors and the Collector methods. A published clientConfig is never
+// modified: updates are made on a copy, which then replaces it.
+type clientConfig struct {
+	handledHistogramEnabled bool
+	handledHistogramOpts    prom.HistogramOpts
+	handledHistogram        *methodHistogramVec
+
+	streamRecvHistogramEnabled bool
+	streamRecvHistogramOpts    prom.HistogramOpts
+	streamRecvHistogram        *methodHistogramVec
+
+	streamSendHistogramEnabled bool
+	streamSendHistogramOpts    prom.HistogramOpts
+	streamSendHistogram        *methodHistogramVec
+
+	bucketOverrides map[string][]float64
+
+	codeClasses             map[codes.Code]string
+	handledCodeClassEnabled bool
+
+	codeResolvers []CodeResolver
+
+	methodSettings map[string]MethodSettings
+
+	// methods caches the children of the metrics used by each method. It
+	// is replaced on updates, as the children depend on the configuration.
+	methods *methodCache
 }
 
 // NewClientMetrics returns a ClientMetrics object. Use a new instance of
@@ -36,72 +71,209 @@ type ClientMetrics struct {
 // example when wanting to control which metrics are added to a registry as
 // opposed to automatically adding metrics via init functions.
 func NewClientMetrics(counterOpts ...CounterOption) *ClientMetrics {
-	opts := counterOptions(counterOpts)
-	return &ClientMetrics{
-		clientStartedCounter: prom.NewCounterVec(
+	return NewClientMetricsWithOptions(WithCounterOptions(counterOpts...))
+}
+
+// NewClientMetricsWithOptions returns a ClientMetrics object configured by
+// the given options, which fix the collected metrics before the
+// ClientMetrics is registered.
+func NewClientMetricsWithOptions(opts ...ClientMetricsOption) *ClientMetrics {
+	var o clientMetricsOptions
+	for _, opt := range opts {
+		opt.applyToClientMetrics(&o)
+	}
+	m := newClientMetrics(o.counterOpts)
+	m.unchecked = o.unchecked
+	cfg := m.config()
+	cfg.bucketOverrides = o.bucketOverrides
+	for _, configure := range o.configure {
+		configure(cfg)
+	}
+	return m
+}
+
+func newClientMetrics(opts counterOptions) *ClientMetrics {
+	families := metricFamilies{}
+	m := &ClientMetrics{
+		families: families,
+		clientStartedCounter: families.counterVec(
 			opts.apply(prom.CounterOpts{
 				Name: "grpc_client_started_total",
 				Help: "Total number of RPCs started on the client.",
 			}), []string{"grpc_type", "grpc_service", "grpc_method"}),
 
-		clientHandledCounter: prom.NewCounterVec(
+		clientHandledCounter: families.counterVec(
 			opts.apply(prom.CounterOpts{
 				Name: "grpc_client_handled_total",
 				Help: "Total number of RPCs completed by the client, regardless of success or failure.",
 			}), []string{"grpc_type", "grpc_service", "grpc_method", "grpc_code"}),
 
-		clientStreamMsgReceived: prom.NewCounterVec(
+		clientStreamMsgReceived: families.counterVec(
 			opts.apply(prom.CounterOpts{
 				Name: "grpc_client_msg_received_total",
 				Help: "Total number of RPC stream messages received by the client.",
 			}), []string{"grpc_type", "grpc_service", "grpc_method"}),
 
-		clientStreamMsgSent: prom.NewCounterVec(
+		clientStreamMsgSent: families.counterVec(
 			opts.apply(prom.CounterOpts{
 				Name: "grpc_client_msg_sent_total",
 				Help: "Total number of gRPC stream messages sent by the client.",
 			}), []string{"grpc_type", "grpc_service", "grpc_method"}),
 
-		clientHandledHistogramEnabled: false,
-		clientHandledHistogramOpts: prom.HistogramOpts{
+		clientHandledCodeClassCounter: families.counterVec(
+			opts.apply(prom.CounterOpts{
+				Name: "grpc_client_handled_code_class_total",
+				Help: "Total number of RPCs completed by the client, by whether the code is a client or a server error.",
+			}), []string{"grpc_type", "grpc_service", "grpc_method", "grpc_code_class"}),
+	}
+	m.cfg.Store(&clientConfig{
+		handledHistogramOpts: prom.HistogramOpts{
 			Name:    "grpc_client_handling_seconds",
 			Help:    "Histogram of response latency (seconds) of the gRPC until it is finished by the application.",
 			Buckets: prom.DefBuckets,
 		},
-		clientHandledHistogram:           nil,
-		clientStreamRecvHistogramEnabled: false,
-		clientStreamRecvHistogramOpts: prom.HistogramOpts{
+		streamRecvHistogramOpts: prom.HistogramOpts{
 			Name:    "grpc_client_msg_recv_handling_seconds",
 			Help:    "Histogram of response latency (seconds) of the gRPC single message receive.",
 			Buckets: prom.DefBuckets,
 		},
-		clientStreamRecvHistogram:        nil,
-		clientStreamSendHistogramEnabled: false,
-		clientStreamSendHistogramOpts: prom.HistogramOpts{
+		streamSendHistogramOpts: prom.HistogramOpts{
 			Name:    "grpc_client_msg_send_handling_seconds",
 			Help:    "Histogram of response latency (seconds) of the gRPC single message send.",
 			Buckets: prom.DefBuckets,
 		},
-		clientStreamSendHistogram: nil,
+		codeClasses:    DefaultCodeClasses(),
+		methodSettings: defaultMethodSettings(),
+		methods:        newMethodCache(),
+	})
+	return m
+}
+
+// config returns the current configuration, which must not be modified.
+func (m *ClientMetrics) config() *clientConfig {
+	return m.cfg.Load().(*clientConfig)
+}
+
+// resetMethodCache drops the cached children of the metrics. It must be
+// called whenever children are removed from the vectors, e.g. by Reset.
+func (m *ClientMetrics) resetMethodCache() {
+	m.updateConfig(func(*clientConfig) {})
+}
+
+// updateConfig publishes a copy of the current configuration modified by
+// update.
+func (m *ClientMetrics) updateConfig(update func(c *clientConfig)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current := m.config()
+	c := *current
+	update(&c)
+	c.methods = newMethodCache()
+	m.cfg.Store(&c)
+	registerChanged(m.registerers, m.collectors(current), m.collectors(&c))
+}
+
+// collectors returns the vectors of the metrics collected with the given
+// configuration.
+func (m *ClientMetrics) collectors(c *clientConfig) []prom.Collector {
+	collectors := []prom.Collector{
+		m.clientStartedCounter,
+		m.clientHandledCounter,
+		m.clientStreamMsgReceived,
+		m.clientStreamMsgSent,
+	}
+	if c.handledHistogramEnabled {
+		collectors = append(collectors, c.handledHistogram)
+	}
+	if c.streamRecvHistogramEnabled {
+		collectors = append(collectors, c.streamRecvHistogram)
+	}
+	if c.streamSendHistogramEnabled {
+		collectors = append(collectors, c.streamSendHistogram)
+	}
+	if c.handledCodeClassEnabled {
+		collectors = append(collectors, m.clientHandledCodeClassCounter)
+	}
+	return collectors
+}
+
+// MetricFamilies returns the families of the enabled metrics. Unlike
+// Describe, it doesn't depend on the metrics being a checked collector.
+func (m *ClientMetrics) MetricFamilies() []MetricFamily {
+	return m.families.list(m.collectors(m.config()))
+}
+
+// Register registers the metrics with reg. Unlike registering the
+// ClientMetrics as a single Collector, metrics enabled after the call, like
+// histograms, are registered with reg as well.
+func (m *ClientMetrics) Register(reg prom.Registerer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := registerAll(reg, m.collectors(m.config())); err != nil {
+		return err
 	}
+	m.registerers = append(m.registerers, reg)
+	return nil
+}
+
+// MustRegister works like Register but panics where Register would have
+// returned an error.
+func (m *ClientMetrics) MustRegister(reg prom.Registerer) {
+	if err := m.Register(reg); err != nil {
+		panic(err)
+	}
+}
+
+// Unregister unregisters the metrics registered by Register from reg. It
+// reports whether all of them were registered.
+func (m *ClientMetrics) Unregister(reg prom.Registerer) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.registerers = removeRegisterer(m.registerers, reg)
+	return unregisterAll(reg, m.collectors(m.config()))
+}
+
+// Reset deletes all series of all metrics, including metrics that are not
+// enabled. It is meant to isolate tests using the same ClientMetrics, like
+// DefaultClientMetrics, from each other.
+func (m *ClientMetrics) Reset() {
+	cfg := m.config()
+	m.clientStartedCounter.Reset()
+	m.clientHandledCounter.Reset()
+	m.clientStreamMsgReceived.Reset()
+	m.clientStreamMsgSent.Reset()
+	for _, h := range []*methodHistogramVec{cfg.handledHistogram, cfg.streamRecvHistogram, cfg.streamSendHistogram} {
+		if h != nil {
+			h.Reset()
+		}
+	}
+	m.clientHandledCodeClassCounter.Reset()
+	m.resetMethodCache()
 }
 
 // Describe sends the super-set of all possible descriptors of metrics
 // collected by this Collector to the provided channel and returns once
 // the last descriptor has been sent.
 func (m *ClientMetrics) Describe(ch chan<- *prom.Desc) {
+	if m.unchecked {
+		return
+	}
+	cfg := m.config()
 	m.clientStartedCounter.Describe(ch)
 	m.clientHandledCounter.Describe(ch)
 	m.clientStreamMsgReceived.Describe(ch)
 	m.clientStreamMsgSent.Describe(ch)
-	if m.clientHandledHistogramEnabled {
-		m.clientHandledHistogram.Describe(ch)
+	if cfg.handledHistogramEnabled {
+		cfg.handledHistogram.Describe(ch)
+	}
+	if cfg.streamRecvHistogramEnabled {
+		cfg.streamRecvHistogram.Describe(ch)
 	}
-	if m.clientStreamRecvHistogramEnabled {
-		m.clientStreamRecvHistogram.Describe(ch)
+	if cfg.streamSendHistogramEnabled {
+		cfg.streamSendHistogram.Describe(ch)
 	}
-	if m.clientStreamSendHistogramEnabled {
-		m.clientStreamSendHistogram.Describe(ch)
+	if cfg.handledCodeClassEnabled {
+		m.clientHandledCodeClassCounter.Describe(ch)
 	}
 }
 
@@ -109,100 +281,236 @@ func (m *ClientMetrics) Describe(ch chan<- *prom.Desc) {
 // metrics. The implementation sends each collected metric via the
 // provided channel and returns once the last metric has been sent.
 func (m *ClientMetrics) Collect(ch chan<- prom.Metric) {
+	cfg := m.config()
 	m.clientStartedCounter.Collect(ch)
 	m.clientHandledCounter.Collect(ch)
 	m.clientStreamMsgReceived.Collect(ch)
 	m.clientStreamMsgSent.Collect(ch)
-	if m.clientHandledHistogramEnabled {
-		m.clientHandledHistogram.Collect(ch)
+	if cfg.handledHistogramEnabled {
+		cfg.handledHistogram.Collect(ch)
 	}
-	if m.clientStreamRecvHistogramEnabled {
-		m.clientStreamRecvHistogram.Collect(ch)
+	if cfg.streamRecvHistogramEnabled {
+		cfg.streamRecvHistogram.Collect(ch)
 	}
-	if m.clientStreamSendHistogramEnabled {
-		m.clientStreamSendHistogram.Collect(ch)
+	if cfg.streamSendHistogramEnabled {
+		cfg.streamSendHistogram.Collect(ch)
 	}
+	if cfg.handledCodeClassEnabled {
+		m.clientHandledCodeClassCounter.Collect(ch)
+	}
+}
+
+// Settings returns the settings which can be changed at runtime.
+func (m *ClientMetrics) Settings() Settings {
+	cfg := m.config()
+	return Settings{
+		Histograms: map[string]bool{
+			cfg.handledHistogramOpts.Name:    cfg.handledHistogramEnabled,
+			cfg.streamRecvHistogramOpts.Name: cfg.streamRecvHistogramEnabled,
+			cfg.streamSendHistogramOpts.Name: cfg.streamSendHistogramEnabled,
+		},
+		Methods: copyMethodSettings(cfg.methodSettings),
+	}
+}
+
+// SetHistogramEnabled enables or disables one of the histograms
+// grpc_client_handling_seconds, grpc_client_msg_recv_handling_seconds and
+// grpc_client_msg_send_handling_seconds while RPCs are in flight. It is
+// enabled with the histogram options last passed for it at construction or
+// to its Enable* method.
+func (m *ClientMetrics) SetHistogramEnabled(name string, enabled bool) error {
+	var update func(c *clientConfig)
+	cfg := m.config()
+	switch name {
+	case cfg.handledHistogramOpts.Name:
+		update = func(c *clientConfig) {
+			if enabled {
+				c.enableHandlingTimeHistogram(nil)
+			} else {
+				c.handledHistogramEnabled, c.handledHistogram = false, nil
+			}
+		}
+	case cfg.streamRecvHistogramOpts.Name:
+		update = func(c *clientConfig) {
+			if enabled {
+				c.enableStreamReceiveTimeHistogram(nil)
+			} else {
+				c.streamRecvHistogramEnabled, c.streamRecvHistogram = false, nil
+			}
+		}
+	case cfg.streamSendHistogramOpts.Name:
+		update = func(c *clientConfig) {
+			if enabled {
+				c.enableStreamSendTimeHistogram(nil)
+			} else {
+				c.streamSendHistogramEnabled, c.streamSendHistogram = false, nil
+			}
+		}
+	default:
+		return unknownHistogram(name)
+	}
+	m.updateConfig(update)
+	return nil
+}
+
+// SetMethodSettings sets the settings of a service or full method name while
+// RPCs are in flight.
+func (m *ClientMetrics) SetMethodSettings(method string, settings MethodSettings) {
+	m.updateConfig(func(c *clientConfig) {
+		c.methodSettings = withMethodSettings(c.methodSettings, method, &settings)
+	})
+}
+
+// DeleteMethodSettings restores the default settings of a service or full
+// method name while RPCs are in flight.
+func (m *ClientMetrics) DeleteMethodSettings(method string) {
+	m.updateConfig(func(c *clientConfig) {
+		c.methodSettings = withMethodSettings(c.methodSettings, method, nil)
+	})
 }
 
 // EnableClientHandlingTimeHistogram turns on recording of handling time of RPCs.
 // Histogram metrics can be very expensive for Prometheus to retain and query.
+//
+// Deprecated: use the WithClientHandlingTimeHistogram option of
+// NewClientMetricsWithOptions, which fixes the collected metrics before the
+// ClientMetrics is registered.
 func (m *ClientMetrics) EnableClientHandlingTimeHistogram(opts ...HistogramOption) {
+	m.updateConfig(func(c *clientConfig) { c.enableHandlingTimeHistogram(opts) })
+}
+
+func (c *clientConfig) enableHandlingTimeHistogram(opts []HistogramOption) {
 	for _, o := range opts {
-		o(&m.clientHandledHistogramOpts)
+		o(&c.handledHistogramOpts)
 	}
-	if !m.clientHandledHistogramEnabled {
-		m.clientHandledHistogram = prom.NewHistogramVec(
-			m.clientHandledHistogramOpts,
+	if !c.handledHistogramEnabled {
+		c.handledHistogram = newMethodHistogramVec(
+			c.handledHistogramOpts,
 			[]string{"grpc_type", "grpc_service", "grpc_method"},
+			c.bucketOverrides,
 		)
 	}
-	m.clientHandledHistogramEnabled = true
+	c.handledHistogramEnabled = true
 }
 
 // EnableClientStreamReceiveTimeHistogram turns on recording of single message receive time of streaming RPCs.
 // Histogram metrics can be very expensive for Prometheus to retain and query.
+//
+// Deprecated: use the WithClientStreamReceiveTimeHistogram option of
+// NewClientMetricsWithOptions.
 func (m *ClientMetrics) EnableClientStreamReceiveTimeHistogram(opts ...HistogramOption) {
+	m.updateConfig(func(c *clientConfig) { c.enableStreamReceiveTimeHistogram(opts) })
+}
+
+func (c *clientConfig) enableStreamReceiveTimeHistogram(opts []HistogramOption) {
 	for _, o := range opts {
-		o(&m.clientStreamRecvHistogramOpts)
+		o(&c.streamRecvHistogramOpts)
 	}
 
-	if !m.clientStreamRecvHistogramEnabled {
-		m.clientStreamRecvHistogram = prom.NewHistogramVec(
-			m.clientStreamRecvHistogramOpts,
+	if !c.streamRecvHistogramEnabled {
+		c.streamRecvHistogram = newMethodHistogramVec(
+			c.streamRecvHistogramOpts,
 			[]string{"grpc_type", "grpc_service", "grpc_method"},
+			c.bucketOverrides,
 		)
 	}
 
-	m.clientStreamRecvHistogramEnabled = true
+	c.streamRecvHistogramEnabled = true
 }
 
 // EnableClientStreamSendTimeHistogram turns on recording of single message send time of streaming RPCs.
 // Histogram metrics can be very expensive for Prometheus to retain and query.
+//
+// Deprecated: use the WithClientStreamSendTimeHistogram option of
+// NewClientMetricsWithOptions.
 func (m *ClientMetrics) EnableClientStreamSendTimeHistogram(opts ...HistogramOption) {
+	m.updateConfig(func(c *clientConfig) { c.enableStreamSendTimeHistogram(opts) })
+}
+
+func (c *clientConfig) enableStreamSendTimeHistogram(opts []HistogramOption) {
 	for _, o := range opts {
-		o(&m.clientStreamSendHistogramOpts)
+		o(&c.streamSendHistogramOpts)
 	}
 
-	if !m.clientStreamSendHistogramEnabled {
-		m.clientStreamSendHistogram = prom.NewHistogramVec(
-			m.clientStreamSendHistogramOpts,
+	if !c.streamSendHistogramEnabled {
+		c.streamSendHistogram = newMethodHistogramVec(
+			c.streamSendHistogramOpts,
 			[]string{"grpc_type", "grpc_service", "grpc_method"},
+			c.bucketOverrides,
 		)
 	}
 
-	m.clientStreamSendHistogramEnabled = true
+	c.streamSendHistogramEnabled = true
+}
+
+// EnableClientHandledCodeClassCounter turns on counting of RPCs completed by
+// the client by the class of their code in grpc_client_handled_code_class_total.
+// Codes are classified by DefaultCodeClasses, with the given overrides applied.
+//
+// Deprecated: use the WithClientHandledCodeClassCounter option of
+// NewClientMetricsWithOptions.
+func (m *ClientMetrics) EnableClientHandledCodeClassCounter(overrides map[codes.Code]string) {
+	m.updateConfig(func(c *clientConfig) { c.enableHandledCodeClassCounter(overrides) })
+}
+
+func (c *clientConfig) enableHandledCodeClassCounter(overrides map[codes.Code]string) {
+	c.codeClasses = mergeCodeClasses(overrides)
+	c.handledCodeClassEnabled = true
+}
+
+// AddClientCodeResolvers adds resolvers used to determine the code reported
+// for errors returned by invokers and client streams. The resolvers are
+// consulted in the order they were added, before falling back to
+// DefaultCodeResolver.
+//
+// Deprecated: use the WithClientCodeResolvers option of
+// NewClientMetricsWithOptions.
+func (m *ClientMetrics) AddClientCodeResolvers(resolvers ...CodeResolver) {
+	m.updateConfig(func(c *clientConfig) { c.addCodeResolvers(resolvers) })
+}
+
+func (c *clientConfig) addCodeResolvers(resolvers []CodeResolver) {
+	// Never append in place, published configurations may share the slice.
+	c.codeResolvers = append(append([]CodeResolver(nil), c.codeResolvers...), resolvers...)
 }
 
 // UnaryClientInterceptor is a gRPC client-side interceptor that provides Prometheus monitoring for Unary RPCs.
 func (m *ClientMetrics) UnaryClientInterceptor() func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
 	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
 		monitor := newClientReporter(m, Unary, method)
-		monitor.SentMessage()
-		err := invoker(ctx, method, req, reply, cc, opts...)
-		if err == nil {
-			monitor.ReceivedMessage()
+		if monitor.settings.Excluded {
+			return invoker(ctx, method, req, reply, cc, opts...)
 		}
-		st, _ := status.FromError(err)
-		monitor.Handled(st.Code())
-		return err
+		return reportUnaryClient(ctx, method, req, reply, cc, invoker, opts, monitor, monitor.cfg.codeResolvers)
 	}
 }
 
 // StreamClientInterceptor is a gRPC client-side interceptor that provides Prometheus monitoring for Streaming RPCs.
+// A stream is reported as handled when it finishes or fails, or when its context is done. Streams abandoned
+// with a context that is never done are never reported as handled.
 func (m *ClientMetrics) StreamClientInterceptor() func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
 	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
 		monitor := newClientReporter(m, clientStreamType(desc), method)
-		clientStream, err := streamer(ctx, desc, cc, method, opts...)
-		if err != nil {
-			st, _ := status.FromError(err)
-			monitor.Handled(st.Code())
-			return nil, err
+		if monitor.settings.Excluded {
+			return streamer(ctx, desc, cc, method, opts...)
 		}
-		return &monitoredClientStream{clientStream, monitor}, nil
+		return reportStreamClient(ctx, desc, cc, method, streamer, opts, monitor, monitor.cfg.codeResolvers)
 	}
 }
 
-func clientStreamType(desc *grpc.StreamDesc) grpcType {
+// NewClientReporter returns a ClientReporter recording an RPC in the
+// metrics. It is a ClientReporterFactory for combining the metrics with
+// other reporters. Code resolvers only apply to the interceptors of
+// ClientMetrics.
+func (m *ClientMetrics) NewClientReporter(_ context.Context, rpcType RPCType, fullMethod string) ClientReporter {
+	r := newClientReporter(m, rpcType, fullMethod)
+	if r.settings.Excluded {
+		return noOpClientReporter{}
+	}
+	return r
+}
+
+func clientStreamType(desc *grpc.StreamDesc) RPCType {
 	if desc.ClientStreams && !desc.ServerStreams {
 		return ClientStream
 	} else if !desc.ClientStreams && desc.ServerStreams {
@@ -214,31 +522,74 @@ func clientStreamType(desc *grpc.StreamDesc) grpcType {
 // monitoredClientStream wraps grpc.ClientStream allowing each Sent/Recv of message to increment counters.
 type monitoredClientStream struct {
 	grpc.ClientStream
-	monitor *clientReporter
+	// serverStreams is set when the server sends a stream of messages. Other
+	// streams finish when RecvMsg returns the single response.
+	serverStreams bool
+	monitor       ClientReporter
+	resolvers     []CodeResolver
+	startTime     time.Time
+
+	handledOnce sync.Once
+	done        chan struct{}
+}
+
+// newMonitoredClientStream wraps the stream and makes sure it is reported as
+// handled exactly once: when it finishes, fails, or when ctx is done because
+// the caller cancelled or abandoned the stream. Abandoned streams opened with
+// a context that is never done, like context.Background(), are never
+// reported as handled.
+func newMonitoredClientStream(ctx context.Context, clientStream grpc.ClientStream, serverStreams bool, monitor ClientReporter, resolvers []CodeResolver, startTime time.Time) *monitoredClientStream {
+	s := &monitoredClientStream{
+		ClientStream:  clientStream,
+		serverStreams: serverStreams,
+		monitor:       monitor,
+		resolvers:     resolvers,
+		startTime:     startTime,
+		done:          make(chan struct{}),
+	}
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				s.handled(codeFromContextError(ctx.Err()))
+			case <-s.done:
+			}
+		}()
+	}
+	return s
+}
+
+func (s *monitoredClientStream) handled(code codes.Code) {
+	s.handledOnce.Do(func() {
+		s.monitor.Handled(code, time.Since(s.startTime))
+		close(s.done)
+	})
+}
+
+func (s *monitoredClientStream) Header() (metadata.MD, error) {
+	md, err := s.ClientStream.Header()
+	if err != nil {
+		s.handled(resolveCode(s.resolvers, err))
+	}
+	return md, err
 }
 
 func (s *monitoredClientStream) SendMsg(m interface{}) error {
-	timer := s.monitor.SendMessageTimer()
+	start := time.Now()
 	err := s.ClientStream.SendMsg(m)
-	timer.ObserveDuration()
-	if err == nil {
-		s.monitor.SentMessage()
-	}
+	s.monitor.SentMessage(err, time.Since(start))
 	return err
 }
 
 func (s *monitoredClientStream) RecvMsg(m interface{}) error {
-	timer := s.monitor.ReceiveMessageTimer()
+	start := time.Now()
 	err := s.ClientStream.RecvMsg(m)
-	timer.ObserveDuration()
-
-	if err == nil {
-		s.monitor.ReceivedMessage()
-	} else if err == io.EOF {
-		s.monitor.Handled(codes.OK)
-	} else {
-		st, _ := status.FromError(err)
-		s.monitor.Handled(st.Code())
+	s.monitor.ReceivedMessage(err, time.Since(start))
+
+	if err == io.EOF || (err == nil && !s.serverStreams) {
+		s.handled(codes.OK)
+	} else if err != nil {
+		s.handled(resolveCode(s.resolvers, err))
 	}
 	return err
 }
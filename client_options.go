@@ -0,0 +1,103 @@
+package grpc_prometheus
+
+import "google.golang.org/grpc/codes"
+
+// A ClientMetricsOption configures ClientMetrics when calling
+// NewClientMetricsWithOptions. CounterOption values are ClientMetricsOptions
+// too and apply to all counters.
+type ClientMetricsOption interface {
+	applyToClientMetrics(*clientMetricsOptions)
+}
+
+type clientMetricsOptions struct {
+	counterOpts     counterOptions
+	bucketOverrides map[string][]float64
+	unchecked       bool
+	configure       []func(c *clientConfig)
+}
+
+func (f CounterOption) applyToClientMetrics(o *clientMetricsOptions) {
+	o.counterOpts = append(o.counterOpts, f)
+}
+
+type clientMetricsOptionFunc func(*clientMetricsOptions)
+
+func (f clientMetricsOptionFunc) applyToClientMetrics(o *clientMetricsOptions) {
+	f(o)
+}
+
+// configureClient returns a ClientMetricsOption applying configure to the
+// initial configuration of the ClientMetrics.
+func configureClient(configure func(c *clientConfig)) ClientMetricsOption {
+	return clientMetricsOptionFunc(func(o *clientMetricsOptions) {
+		o.configure = append(o.configure, configure)
+	})
+}
+
+// WithClientHandlingTimeHistogram enables the grpc_client_handling_seconds
+// histogram, configured by the given histogram options. Histogram metrics can
+// be very expensive for Prometheus to retain and query.
+func WithClientHandlingTimeHistogram(opts ...HistogramOption) ClientMetricsOption {
+	return configureClient(func(c *clientConfig) { c.enableHandlingTimeHistogram(opts) })
+}
+
+// WithClientStreamReceiveTimeHistogram enables the
+// grpc_client_msg_recv_handling_seconds histogram of single message receive
+// time of streaming RPCs.
+func WithClientStreamReceiveTimeHistogram(opts ...HistogramOption) ClientMetricsOption {
+	return configureClient(func(c *clientConfig) { c.enableStreamReceiveTimeHistogram(opts) })
+}
+
+// WithClientStreamSendTimeHistogram enables the
+// grpc_client_msg_send_handling_seconds histogram of single message send time
+// of streaming RPCs.
+func WithClientStreamSendTimeHistogram(opts ...HistogramOption) ClientMetricsOption {
+	return configureClient(func(c *clientConfig) { c.enableStreamSendTimeHistogram(opts) })
+}
+
+// WithClientHistogramBucketOverrides overrides the buckets of all client
+// histograms for the given services, e.g. "mwitkow.testproto.TestService",
+// and full method names, e.g. "/mwitkow.testproto.TestService/Ping". Method
+// overrides take precedence over service overrides.
+func WithClientHistogramBucketOverrides(overrides map[string][]float64) ClientMetricsOption {
+	return clientMetricsOptionFunc(func(o *clientMetricsOptions) {
+		o.bucketOverrides = copyBucketOverrides(o.bucketOverrides, overrides)
+	})
+}
+
+// WithClientHandledCodeClassCounter enables counting of RPCs by the class of
+// their code in grpc_client_handled_code_class_total, with the given
+// overrides of DefaultCodeClasses applied.
+func WithClientHandledCodeClassCounter(overrides map[codes.Code]string) ClientMetricsOption {
+	return configureClient(func(c *clientConfig) { c.enableHandledCodeClassCounter(overrides) })
+}
+
+// WithClientCodeResolvers adds resolvers used to determine the code reported
+// for errors returned by invokers and client streams, consulted in order
+// before DefaultCodeResolver.
+func WithClientCodeResolvers(resolvers ...CodeResolver) ClientMetricsOption {
+	return configureClient(func(c *clientConfig) { c.addCodeResolvers(resolvers) })
+}
+
+// WithClientExcludedMethods sets the services and full method names whose
+// RPCs the interceptors don't record. It replaces the default exclusion of
+// the service of packages/metricsservice; calling it without methods records
+// all RPCs.
+func WithClientExcludedMethods(methods ...string) ClientMetricsOption {
+	return configureClient(func(c *clientConfig) {
+		c.methodSettings = withExcludedMethods(c.methodSettings, methods)
+	})
+}
+
+// WithClientUncheckedCollector makes the ClientMetrics an unchecked
+// collector, whose Describe sends no descriptors, so that pedantic registries
+// accept histograms enabled at runtime with SetHistogramEnabled when the
+// ClientMetrics is registered as a single collector. Other registries don't
+// check collected metrics against the descriptors, and it is not needed with
+// Register. Tooling reading the metrics of the ClientMetrics must use
+// MetricFamilies rather than Describe, as packages/metricdesc does.
+func WithClientUncheckedCollector() ClientMetricsOption {
+	return clientMetricsOptionFunc(func(o *clientMetricsOptions) {
+		o.unchecked = true
+	})
+}
@@ -6,74 +6,64 @@ package grpc_prometheus
 import (
 	"time"
 
-	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc/codes"
 )
 
 type clientReporter struct {
-	metrics     *ClientMetrics
-	rpcType     grpcType
-	serviceName string
-	methodName  string
-	startTime   time.Time
+	metrics  *ClientMetrics
+	cfg      *clientConfig
+	method   *clientMethodMetrics
+	settings MethodSettings
+	rpcType  RPCType
 }
 
-func newClientReporter(m *ClientMetrics, rpcType grpcType, fullMethod string) *clientReporter {
-	r := &clientReporter{
-		metrics: m,
-		rpcType: rpcType,
+func newClientReporter(m *ClientMetrics, rpcType RPCType, fullMethod string) *clientReporter {
+	cfg := m.config()
+	method := cfg.methods.get(rpcType, fullMethod, newClientMethodMetrics).(*clientMethodMetrics)
+	return &clientReporter{
+		metrics:  m,
+		cfg:      cfg,
+		method:   method,
+		settings: method.settings.get(cfg.methodSettings, method.serviceName, fullMethod),
+		rpcType:  rpcType,
 	}
-	if r.metrics.clientHandledHistogramEnabled {
-		r.startTime = time.Now()
-	}
-	r.serviceName, r.methodName = splitMethodName(fullMethod)
-	r.metrics.clientStartedCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Inc()
-	return r
-}
-
-// timer is a helper interface to time functions.
-type timer interface {
-	ObserveDuration() time.Duration
-}
-
-type noOpTimer struct {
 }
 
-func (noOpTimer) ObserveDuration() time.Duration {
-	return 0
+func (r *clientReporter) Started() {
+	r.method.started.get(r.metrics.clientStartedCounter, r.method.labels).Inc()
 }
 
-var emptyTimer = noOpTimer{}
-
-func (r *clientReporter) ReceiveMessageTimer() timer {
-	if r.metrics.clientStreamRecvHistogramEnabled {
-		hist := r.metrics.clientStreamRecvHistogram.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName)
-		return prometheus.NewTimer(hist)
+func (r *clientReporter) ReceivedMessage(err error, elapsed time.Duration) {
+	if r.cfg.streamRecvHistogramEnabled && r.rpcType != Unary && !r.settings.NoHistograms {
+		r.method.recvHistogram.get(r.cfg.streamRecvHistogram, r.method.serviceName, r.method.fullMethod, r.method.labels).Observe(elapsed.Seconds())
 	}
-
-	return emptyTimer
-}
-
-func (r *clientReporter) ReceivedMessage() {
-	r.metrics.clientStreamMsgReceived.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Inc()
-}
-
-func (r *clientReporter) SendMessageTimer() timer {
-	if r.metrics.clientStreamSendHistogramEnabled {
-		hist := r.metrics.clientStreamSendHistogram.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName)
-		return prometheus.NewTimer(hist)
+	if err == nil {
+		r.method.received.get(r.metrics.clientStreamMsgReceived, r.method.labels).Inc()
 	}
-
-	return emptyTimer
 }
 
-func (r *clientReporter) SentMessage() {
-	r.metrics.clientStreamMsgSent.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Inc()
+func (r *clientReporter) SentMessage(err error, elapsed time.Duration) {
+	if r.cfg.streamSendHistogramEnabled && r.rpcType != Unary && !r.settings.NoHistograms {
+		r.method.sendHistogram.get(r.cfg.streamSendHistogram, r.method.serviceName, r.method.fullMethod, r.method.labels).Observe(elapsed.Seconds())
+	}
+	if err == nil {
+		r.method.sent.get(r.metrics.clientStreamMsgSent, r.method.labels).Inc()
+	}
 }
 
-func (r *clientReporter) Handled(code codes.Code) {
-	r.metrics.clientHandledCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName, code.String()).Inc()
-	if r.metrics.clientHandledHistogramEnabled {
-		r.metrics.clientHandledHistogram.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Observe(time.Since(r.startTime).Seconds())
+func (r *clientReporter) Handled(code codes.Code, elapsed time.Duration) {
+	if int(code) < numCachedCodes {
+		r.method.handled[code].getWith(r.metrics.clientHandledCounter, r.method.labels, code.String()).Inc()
+		if r.cfg.handledCodeClassEnabled {
+			r.method.handledCodeClass[code].getWith(r.metrics.clientHandledCodeClassCounter, r.method.labels, codeClass(r.cfg.codeClasses, code)).Inc()
+		}
+	} else {
+		r.metrics.clientHandledCounter.WithLabelValues(string(r.rpcType), r.method.serviceName, r.method.methodName, code.String()).Inc()
+		if r.cfg.handledCodeClassEnabled {
+			r.metrics.clientHandledCodeClassCounter.WithLabelValues(string(r.rpcType), r.method.serviceName, r.method.methodName, codeClass(r.cfg.codeClasses, code)).Inc()
+		}
+	}
+	if r.cfg.handledHistogramEnabled && !r.settings.NoHistograms {
+		r.method.handledHistogram.get(r.cfg.handledHistogram, r.method.serviceName, r.method.fullMethod, r.method.labels).Observe(elapsed.Seconds())
 	}
 }
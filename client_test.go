@@ -5,6 +5,7 @@ package grpc_prometheus
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"net"
 	"testing"
@@ -12,6 +13,7 @@ import (
 
 	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/grpc"
@@ -71,11 +73,7 @@ func (s *ClientInterceptorTestSuite) SetupTest() {
 	s.ctx, s.cancel = context.WithTimeout(context.TODO(), 2*time.Second)
 
 	// Make sure every test starts with same fresh, intialized metric state.
-	DefaultClientMetrics.clientStartedCounter.Reset()
-	DefaultClientMetrics.clientHandledCounter.Reset()
-	DefaultClientMetrics.clientHandledHistogram.Reset()
-	DefaultClientMetrics.clientStreamMsgReceived.Reset()
-	DefaultClientMetrics.clientStreamMsgSent.Reset()
+	DefaultClientMetrics.Reset()
 }
 
 func (s *ClientInterceptorTestSuite) TearDownSuite() {
@@ -99,13 +97,13 @@ func (s *ClientInterceptorTestSuite) TestUnaryIncrementsMetrics() {
 	require.NoError(s.T(), err)
 	requireValue(s.T(), 1, DefaultClientMetrics.clientStartedCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty"))
 	requireValue(s.T(), 1, DefaultClientMetrics.clientHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty", "OK"))
-	requireValueHistCount(s.T(), 1, DefaultClientMetrics.clientHandledHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty"))
+	requireValueHistCount(s.T(), 1, DefaultClientMetrics.config().handledHistogram.defaultVec.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty"))
 
 	_, err = s.testClient.PingError(s.ctx, &pb_testproto.PingRequest{ErrorCodeReturned: uint32(codes.FailedPrecondition)}) // should return with code=FailedPrecondition
 	require.Error(s.T(), err)
 	requireValue(s.T(), 1, DefaultClientMetrics.clientStartedCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError"))
 	requireValue(s.T(), 1, DefaultClientMetrics.clientHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError", "FailedPrecondition"))
-	requireValueHistCount(s.T(), 1, DefaultClientMetrics.clientHandledHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError"))
+	requireValueHistCount(s.T(), 1, DefaultClientMetrics.config().handledHistogram.defaultVec.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError"))
 }
 
 func (s *ClientInterceptorTestSuite) TestStartedStreamingIncrementsStarted() {
@@ -136,7 +134,7 @@ func (s *ClientInterceptorTestSuite) TestStreamingIncrementsMetrics() {
 	requireValue(s.T(), 1, DefaultClientMetrics.clientHandledCounter.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList", "OK"))
 	requireValue(s.T(), countListResponses, DefaultClientMetrics.clientStreamMsgReceived.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList"))
 	requireValue(s.T(), 1, DefaultClientMetrics.clientStreamMsgSent.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList"))
-	requireValueHistCount(s.T(), 1, DefaultClientMetrics.clientHandledHistogram.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList"))
+	requireValueHistCount(s.T(), 1, DefaultClientMetrics.config().handledHistogram.defaultVec.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList"))
 
 	ss, err := s.testClient.PingList(s.ctx, &pb_testproto.PingRequest{ErrorCodeReturned: uint32(codes.FailedPrecondition)}) // should return with code=FailedPrecondition
 	require.NoError(s.T(), err, "PingList must not fail immediately")
@@ -148,5 +146,221 @@ func (s *ClientInterceptorTestSuite) TestStreamingIncrementsMetrics() {
 
 	requireValue(s.T(), 2, DefaultClientMetrics.clientStartedCounter.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList"))
 	requireValue(s.T(), 1, DefaultClientMetrics.clientHandledCounter.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList", "FailedPrecondition"))
-	requireValueHistCount(s.T(), 2, DefaultClientMetrics.clientHandledHistogram.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList"))
+	requireValueHistCount(s.T(), 2, DefaultClientMetrics.config().handledHistogram.defaultVec.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList"))
+}
+
+func (s *ClientInterceptorTestSuite) TestStreamingHandledOnlyOnceAfterEOF() {
+	ss, err := s.testClient.PingList(s.ctx, &pb_testproto.PingRequest{}) // should return with code=OK
+	require.NoError(s.T(), err)
+	for {
+		if _, err := ss.Recv(); err == io.EOF {
+			break
+		}
+	}
+	_, err = ss.Recv()
+	require.Error(s.T(), err, "Recv after EOF must fail")
+
+	requireValue(s.T(), 1, DefaultClientMetrics.clientHandledCounter.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList", "OK"))
+	requireValueHistCount(s.T(), 1, DefaultClientMetrics.config().handledHistogram.defaultVec.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList"))
+}
+
+func (s *ClientInterceptorTestSuite) TestStreamingCancelledIsHandled() {
+	ctx, cancel := context.WithCancel(s.ctx)
+	ss, err := s.testClient.PingList(ctx, &pb_testproto.PingRequest{})
+	require.NoError(s.T(), err)
+	_, err = ss.Recv()
+	require.NoError(s.T(), err, "reading pingList shouldn't fail")
+
+	// Streams abandoned by previous tests are reported as cancelled when their context is done, so only
+	// compare against the values seen before this stream is abandoned.
+	handledCanceled := DefaultClientMetrics.clientHandledCounter.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList", "Canceled")
+	handledHist := DefaultClientMetrics.config().handledHistogram.defaultVec.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList")
+	before, beforeHist := int(testutil.ToFloat64(handledCanceled)), int(toFloat64HistCount(handledHist))
+
+	// Abandon the stream without reading it to the end.
+	cancel()
+
+	requireValueWithRetry(s.ctx, s.T(), before+1, handledCanceled)
+	for {
+		if _, err := ss.Recv(); err != nil {
+			break
+		}
+	}
+	requireValue(s.T(), before+1, handledCanceled)
+	requireValueHistCount(s.T(), beforeHist+1, handledHist)
+}
+
+func (s *ClientInterceptorTestSuite) TestClientStreamingHandledOnceAsOK() {
+	ctx, cancel := context.WithCancel(s.ctx)
+	ss, err := s.testClient.PingStream(ctx)
+	require.NoError(s.T(), err)
+	for i := 0; i < 3; i++ {
+		require.NoError(s.T(), ss.Send(&pb_testproto.PingRequest{}))
+	}
+	resp, err := ss.CloseAndRecv()
+	require.NoError(s.T(), err)
+	require.EqualValues(s.T(), 3, resp.Counter)
+
+	// Cancelling the context of a finished stream must not report it again.
+	cancel()
+
+	requireValue(s.T(), 1, DefaultClientMetrics.clientStartedCounter.WithLabelValues("client_stream", "mwitkow.testproto.TestService", "PingStream"))
+	requireValue(s.T(), 1, DefaultClientMetrics.clientHandledCounter.WithLabelValues("client_stream", "mwitkow.testproto.TestService", "PingStream", "OK"))
+	requireValue(s.T(), 0, DefaultClientMetrics.clientHandledCounter.WithLabelValues("client_stream", "mwitkow.testproto.TestService", "PingStream", "Canceled"))
+	requireValue(s.T(), 3, DefaultClientMetrics.clientStreamMsgSent.WithLabelValues("client_stream", "mwitkow.testproto.TestService", "PingStream"))
+	requireValueHistCount(s.T(), 1, DefaultClientMetrics.config().handledHistogram.defaultVec.WithLabelValues("client_stream", "mwitkow.testproto.TestService", "PingStream"))
+}
+
+func TestClientHandledCodeClassCounter(t *testing.T) {
+	m := NewClientMetricsWithOptions(WithClientHandledCodeClassCounter(nil))
+	interceptor := m.UnaryClientInterceptor()
+	for _, code := range []codes.Code{codes.OK, codes.InvalidArgument, codes.Unavailable} {
+		interceptor(context.TODO(), "/mwitkow.testproto.TestService/PingError", nil, nil, nil,
+			func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+				return status.Error(code, "Userspace error.")
+			})
+	}
+
+	requireValue(t, 1, m.clientHandledCodeClassCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError", "ok"))
+	requireValue(t, 1, m.clientHandledCodeClassCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError", "client_error"))
+	requireValue(t, 1, m.clientHandledCodeClassCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError", "server_error"))
+}
+
+func TestClientCodeResolvers(t *testing.T) {
+	m := NewClientMetricsWithOptions(WithClientCodeResolvers(ResolveErrorIs(errTestNotFound, codes.NotFound)))
+	interceptor := m.UnaryClientInterceptor()
+	for _, err := range []error{
+		fmt.Errorf("lookup: %w", errTestNotFound),
+		fmt.Errorf("wrapped: %w", status.Error(codes.FailedPrecondition, "Userspace error.")),
+	} {
+		interceptor(context.TODO(), "/mwitkow.testproto.TestService/PingError", nil, nil, nil,
+			func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+				return err
+			})
+	}
+
+	requireValue(t, 1, m.clientHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError", "NotFound"))
+	requireValue(t, 1, m.clientHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError", "FailedPrecondition"))
+}
+
+// fakeClientStream is a grpc.ClientStream receiving msgs messages before
+// io.EOF, for calling stream interceptors directly.
+type fakeClientStream struct {
+	grpc.ClientStream
+	msgs int
+}
+
+func (s *fakeClientStream) SendMsg(interface{}) error {
+	return nil
+}
+
+func (s *fakeClientStream) RecvMsg(interface{}) error {
+	if s.msgs == 0 {
+		return io.EOF
+	}
+	s.msgs--
+	return nil
+}
+
+func BenchmarkClientUnaryInterceptor(b *testing.B) {
+	m := NewClientMetricsWithOptions(WithClientHandlingTimeHistogram(), WithClientHandledCodeClassCounter(nil))
+	interceptor := m.UnaryClientInterceptor()
+	invoker := func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+		return nil
+	}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			interceptor(ctx, "/mwitkow.testproto.TestService/Ping", nil, nil, nil, invoker)
+		}
+	})
+}
+
+func BenchmarkClientStreamInterceptor(b *testing.B) {
+	m := NewClientMetricsWithOptions(
+		WithClientHandlingTimeHistogram(),
+		WithClientStreamReceiveTimeHistogram(),
+		WithClientStreamSendTimeHistogram(),
+		WithClientHandledCodeClassCounter(nil),
+	)
+	interceptor := m.StreamClientInterceptor()
+	desc := &grpc.StreamDesc{ServerStreams: true}
+	streamer := func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &fakeClientStream{msgs: 10}, nil
+	}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			stream, _ := interceptor(ctx, desc, nil, "/mwitkow.testproto.TestService/PingList", streamer)
+			stream.SendMsg(nil)
+			for stream.RecvMsg(nil) == nil {
+			}
+		}
+	})
+}
+
+func TestClientHistogramBucketOverrides(t *testing.T) {
+	m := NewClientMetricsWithOptions(
+		WithClientHistogramBucketOverrides(map[string][]float64{"/mwitkow.testproto.TestService/PingList": {0.5}}),
+		WithClientHandlingTimeHistogram(WithHistogramBuckets([]float64{1})),
+		WithClientStreamReceiveTimeHistogram(WithHistogramBuckets([]float64{2})),
+		WithClientStreamSendTimeHistogram(WithHistogramBuckets([]float64{3})),
+	)
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(m))
+
+	streamer := func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &fakeClientStream{msgs: 1}, nil
+	}
+	for _, method := range []string{"/mwitkow.testproto.TestService/PingList", "/mwitkow.testproto.TestService/PingStream"} {
+		stream, err := m.StreamClientInterceptor()(context.Background(), &grpc.StreamDesc{ServerStreams: true}, nil, method, streamer)
+		require.NoError(t, err)
+		stream.SendMsg(nil)
+		for stream.RecvMsg(nil) == nil {
+		}
+	}
+
+	for name, defaultBuckets := range map[string][]float64{
+		"grpc_client_handling_seconds":          {1},
+		"grpc_client_msg_recv_handling_seconds": {2},
+		"grpc_client_msg_send_handling_seconds": {3},
+	} {
+		require.Equal(t, map[string][]float64{
+			"mwitkow.testproto.TestService/PingList":   {0.5},
+			"mwitkow.testproto.TestService/PingStream": defaultBuckets,
+		}, gatherBuckets(t, reg, name), name)
+	}
+}
+
+func TestClientMetricsRegistration(t *testing.T) {
+	m := NewClientMetrics()
+	reg := prometheus.NewPedanticRegistry()
+	m.MustRegister(reg)
+
+	m.EnableClientHandlingTimeHistogram()
+	m.UnaryClientInterceptor()(context.TODO(), "/mwitkow.testproto.TestService/PingEmpty", nil, nil, nil,
+		func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+			return nil
+		})
+	require.Contains(t, gatherNames(t, reg), "grpc_client_handling_seconds", "histograms enabled after registration must be registered")
+
+	m.Reset()
+	require.Empty(t, gatherNames(t, reg))
+	require.True(t, m.Unregister(reg))
+	require.False(t, m.Unregister(reg), "metrics must not be registered anymore")
+}
+
+func TestClientMetricsCounterOptions(t *testing.T) {
+	m := NewClientMetrics(func(o *prometheus.CounterOpts) { o.Namespace = "myapp" })
+	reg := prometheus.NewPedanticRegistry()
+	m.MustRegister(reg)
+	m.UnaryClientInterceptor()(context.TODO(), "/mwitkow.testproto.TestService/PingEmpty", nil, nil, nil,
+		func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+			return nil
+		})
+	require.Contains(t, gatherNames(t, reg), "myapp_grpc_client_started_total")
 }
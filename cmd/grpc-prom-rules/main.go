@@ -0,0 +1,58 @@
+// Command grpc-prom-rules writes Prometheus recording and alerting rules for
+// the metrics produced by go-grpc-prometheus.
+//
+// The metric names and labels are read from ServerMetrics or ClientMetrics
+// configured like the instrumented service, so the rules follow the metric
+// namespace, subsystem and extension labels used there. Recording rules cover
+// traffic, handled and error rates, error ratios and, when histograms are
+// enabled, the 99th percentile latency. Alerting rules are multi-window,
+// multi-burn-rate alerts on the error budget of the availability objective.
+//
+// Usage:
+//
+//	grpc-prom-rules -side=server -selector='job="foo"' -objective=0.999 > grpc.rules.yml
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func main() {
+	var c config
+	var extensionLabels string
+	flag.StringVar(&c.side, "side", "server", "Generate rules for the server or client metrics.")
+	flag.StringVar(&c.namespace, "namespace", "", "Namespace configured on the metrics.")
+	flag.StringVar(&c.subsystem, "subsystem", "", "Subsystem configured on the metrics.")
+	flag.StringVar(&extensionLabels, "extension-labels", "", "Comma separated custom labels of the handled counter added by a ServerExtension.")
+	flag.BoolVar(&c.histograms, "histograms", false, "Whether the handling time histogram is enabled.")
+	flag.StringVar(&c.selector, "selector", "", `Label selector restricting the rules to the service, e.g. job="foo".`)
+	flag.Float64Var(&c.objective, "objective", 0.999, "Availability objective the error budget alerts are based on.")
+	output := flag.String("output", "", "File to write the rules to, defaults to stdout.")
+	flag.Parse()
+
+	if extensionLabels != "" {
+		c.extensionLabels = strings.Split(extensionLabels, ",")
+	}
+	if c.objective <= 0 || c.objective >= 1 {
+		fmt.Fprintln(os.Stderr, "grpc-prom-rules: objective must be between 0 and 1")
+		os.Exit(2)
+	}
+
+	out := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "grpc-prom-rules: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := generateRules(out, c); err != nil {
+		fmt.Fprintf(os.Stderr, "grpc-prom-rules: %v\n", err)
+		os.Exit(1)
+	}
+}
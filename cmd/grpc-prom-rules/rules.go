@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/grpc-ecosystem/go-grpc-prometheus/packages/metricdesc"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// config describes the metrics the rules are generated for, and the service
+// level objective the alerts are based on.
+type config struct {
+	// side is either "server" or "client".
+	side            string
+	namespace       string
+	subsystem       string
+	extensionLabels []string
+	histograms      bool
+	// selector is a PromQL label selector without braces, e.g. `job="foo"`.
+	selector string
+	// objective is the availability objective, e.g. 0.999.
+	objective float64
+}
+
+// rateWindows are the windows recording rules are generated for. They cover
+// all windows used by the burn rate alerts.
+var rateWindows = []string{"5m", "30m", "1h", "2h", "6h", "1d"}
+
+// burnRateAlerts are the multi-window, multi-burn-rate alerts recommended by
+// the Site Reliability Workbook.
+var burnRateAlerts = []struct {
+	longWindow  string
+	shortWindow string
+	factor      string
+	severity    string
+}{
+	{"1h", "5m", "14.4", "page"},
+	{"6h", "30m", "6", "page"},
+	{"1d", "2h", "3", "ticket"},
+}
+
+// extension exposes the configured labels as custom labels of the handled
+// counter, so the generated rules aggregate by them.
+type extension struct {
+	grpc_prometheus.DefaultExtension
+	labels []string
+}
+
+func (e extension) ServerHandledCounterCustomLabels() []string {
+	return e.labels
+}
+
+func (e extension) ServerHandledCounterValues(context.Context) []string {
+	return make([]string, len(e.labels))
+}
+
+// metrics returns the collector producing the metrics described by the
+// configuration.
+func (c config) metrics() (prom.Collector, error) {
+	counterOpt := grpc_prometheus.CounterOption(func(o *prom.CounterOpts) {
+		o.Namespace = c.namespace
+		o.Subsystem = c.subsystem
+	})
+	histogramOpt := func(o *prom.HistogramOpts) {
+		o.Namespace = c.namespace
+		o.Subsystem = c.subsystem
+	}
+	switch c.side {
+	case "server":
+		opts := []grpc_prometheus.ServerMetricsOption{grpc_prometheus.WithExtension(extension{labels: c.extensionLabels}), counterOpt}
+		if c.histograms {
+			opts = append(opts, grpc_prometheus.WithHandlingTimeHistogram(histogramOpt))
+		}
+		return grpc_prometheus.NewServerMetricsWithOptions(opts...), nil
+	case "client":
+		if len(c.extensionLabels) > 0 {
+			return nil, fmt.Errorf("extension labels are only supported for server metrics")
+		}
+		opts := []grpc_prometheus.ClientMetricsOption{counterOpt}
+		if c.histograms {
+			opts = append(opts, grpc_prometheus.WithClientHandlingTimeHistogram(histogramOpt))
+		}
+		return grpc_prometheus.NewClientMetricsWithOptions(opts...), nil
+	}
+	return nil, fmt.Errorf("unknown side %q, must be server or client", c.side)
+}
+
+type rule struct {
+	record string
+	alert  string
+	expr   string
+	for_   string
+	labels map[string]string
+	annots map[string]string
+}
+
+type ruleGroup struct {
+	name  string
+	rules []rule
+}
+
+// generateRules writes the recording and alerting rules for the configured
+// metrics as a Prometheus rule file.
+func generateRules(w io.Writer, c config) error {
+	m, err := c.metrics()
+	if err != nil {
+		return err
+	}
+	families, err := metricdesc.Describe(m)
+	if err != nil {
+		return err
+	}
+	prefix := "grpc_" + c.side
+	started, ok := metricdesc.Find(families, prefix+"_started_total")
+	if !ok {
+		return fmt.Errorf("metrics don't describe %s_started_total", prefix)
+	}
+	handled, ok := metricdesc.Find(families, prefix+"_handled_total")
+	if !ok {
+		return fmt.Errorf("metrics don't describe %s_handled_total", prefix)
+	}
+	by := strings.Join(aggregationLabels(handled), ", ")
+
+	var recording []rule
+	for _, window := range rateWindows {
+		recording = append(recording,
+			rule{
+				record: fmt.Sprintf("grpc_method:%s_started:rate%s", prefix, window),
+				expr:   fmt.Sprintf("sum by (%s) (rate(%s[%s]))", strings.Join(aggregationLabels(started), ", "), c.series(started.Name, ""), window),
+			},
+			rule{
+				record: fmt.Sprintf("grpc_method:%s_handled:rate%s", prefix, window),
+				expr:   fmt.Sprintf("sum by (%s) (rate(%s[%s]))", by, c.series(handled.Name, ""), window),
+			},
+			rule{
+				record: fmt.Sprintf("grpc_method:%s_handled_errors:rate%s", prefix, window),
+				expr:   fmt.Sprintf("sum by (%s) (rate(%s[%s]))", by, c.series(handled.Name, fmt.Sprintf(`grpc_code=~"%s"`, metricdesc.CodeClassRegexp(grpc_prometheus.CodeClassServerError))), window),
+			},
+			rule{
+				record: fmt.Sprintf("grpc_method:%s_error_ratio:rate%s", prefix, window),
+				expr: fmt.Sprintf("grpc_method:%s_handled_errors:rate%s\n/\ngrpc_method:%s_handled:rate%s",
+					prefix, window, prefix, window),
+			},
+		)
+	}
+	if histogram, ok := metricdesc.Find(families, prefix+"_handling_seconds"); ok {
+		for _, window := range []string{"5m", "1h"} {
+			recording = append(recording, rule{
+				record: fmt.Sprintf("grpc_method:%s_handling_seconds:p99_rate%s", prefix, window),
+				expr: fmt.Sprintf("histogram_quantile(0.99, sum by (%s, le) (rate(%s[%s])))",
+					strings.Join(aggregationLabels(histogram), ", "), c.series(histogram.Name+"_bucket", ""), window),
+			})
+		}
+	}
+
+	objective := strconv.FormatFloat(c.objective, 'f', -1, 64)
+	var alerting []rule
+	for _, a := range burnRateAlerts {
+		alerting = append(alerting, rule{
+			alert: fmt.Sprintf("GRPC%sErrorBudgetBurn", strings.ToUpper(c.side[:1])+c.side[1:]),
+			expr: fmt.Sprintf("grpc_method:%s_error_ratio:rate%s > (%s * (1 - %s))\nand\ngrpc_method:%s_error_ratio:rate%s > (%s * (1 - %s))",
+				prefix, a.longWindow, a.factor, objective, prefix, a.shortWindow, a.factor, objective),
+			for_: "2m",
+			labels: map[string]string{
+				"severity":    a.severity,
+				"long_window": a.longWindow,
+			},
+			annots: map[string]string{
+				"summary":     fmt.Sprintf("gRPC %s error budget burn rate is too high", c.side),
+				"description": fmt.Sprintf("{{ $labels.grpc_service }}/{{ $labels.grpc_method }} is burning its %s availability error budget %sx too fast over the last %s.", objective, a.factor, a.longWindow),
+			},
+		})
+	}
+
+	return writeRuleFile(w, []ruleGroup{
+		{name: prefix + ".rules", rules: recording},
+		{name: prefix + ".alerts", rules: alerting},
+	})
+}
+
+// aggregationLabels returns the labels rules aggregate by: the job, the
+// method and any extension labels of the family.
+func aggregationLabels(f metricdesc.Family) []string {
+	labels := []string{"job"}
+	for _, l := range f.Labels {
+		switch l {
+		case "grpc_type", "grpc_code":
+		default:
+			labels = append(labels, l)
+		}
+	}
+	return labels
+}
+
+// series returns the selector of the named series, restricted by the
+// configured selector and the given matchers.
+func (c config) series(name string, matchers string) string {
+	var all []string
+	if c.selector != "" {
+		all = append(all, c.selector)
+	}
+	if matchers != "" {
+		all = append(all, matchers)
+	}
+	if len(all) == 0 {
+		return name
+	}
+	return name + "{" + strings.Join(all, ",") + "}"
+}
+
+func writeRuleFile(w io.Writer, groups []ruleGroup) error {
+	var b strings.Builder
+	b.WriteString("# Generated by grpc-prom-rules. DO NOT EDIT.\ngroups:\n")
+	for _, g := range groups {
+		fmt.Fprintf(&b, "  - name: %s\n    rules:\n", g.name)
+		for _, r := range g.rules {
+			if r.record != "" {
+				fmt.Fprintf(&b, "      - record: %s\n", r.record)
+			} else {
+				fmt.Fprintf(&b, "      - alert: %s\n", r.alert)
+			}
+			b.WriteString("        expr: |-\n")
+			for _, line := range strings.Split(r.expr, "\n") {
+				fmt.Fprintf(&b, "          %s\n", line)
+			}
+			if r.for_ != "" {
+				fmt.Fprintf(&b, "        for: %s\n", r.for_)
+			}
+			writeMap(&b, "labels", r.labels)
+			writeMap(&b, "annotations", r.annots)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeMap(b *strings.Builder, name string, m map[string]string) {
+	if len(m) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(b, "        %s:\n", name)
+	for _, k := range keys {
+		fmt.Fprintf(b, "          %s: %s\n", k, strconv.Quote(m[k]))
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestGenerateRules(t *testing.T) {
+	for _, testCase := range []struct {
+		golden string
+		config config
+	}{
+		{"server.golden.yml", config{side: "server", objective: 0.999}},
+		{"server_namespace_extension.golden.yml", config{
+			side:            "server",
+			namespace:       "myapp",
+			extensionLabels: []string{"tenant"},
+			histograms:      true,
+			selector:        `job="foo"`,
+			objective:       0.99,
+		}},
+		{"client.golden.yml", config{side: "client", histograms: true, selector: `job="bar"`, objective: 0.9995}},
+	} {
+		t.Run(testCase.golden, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, generateRules(&buf, testCase.config))
+
+			golden := filepath.Join("testdata", testCase.golden)
+			if *update {
+				require.NoError(t, ioutil.WriteFile(golden, buf.Bytes(), 0644))
+			}
+			expected, err := ioutil.ReadFile(golden)
+			require.NoError(t, err)
+			require.Equal(t, string(expected), buf.String())
+		})
+	}
+}
+
+func TestGenerateRulesErrors(t *testing.T) {
+	var buf bytes.Buffer
+	require.Error(t, generateRules(&buf, config{side: "proxy", objective: 0.999}))
+	require.Error(t, generateRules(&buf, config{side: "client", extensionLabels: []string{"tenant"}, objective: 0.999}))
+}
@@ -0,0 +1,60 @@
+package grpc_prometheus
+
+import (
+	"errors"
+
+	"github.com/grpc-ecosystem/go-grpc-prometheus/packages/grpcstatus"
+	"google.golang.org/grpc/codes"
+)
+
+// A CodeResolver returns the gRPC code reported for an error returned by a
+// handler or an invoker, and whether it could resolve the error at all.
+// Resolvers let the interceptors report domain errors, which are only
+// converted to a status later in the interceptor chain, with a proper code
+// instead of Unknown.
+type CodeResolver func(err error) (codes.Code, bool)
+
+// ResolveErrorIs returns a CodeResolver resolving errors matching target,
+// as reported by errors.Is, to code.
+func ResolveErrorIs(target error, code codes.Code) CodeResolver {
+	return func(err error) (codes.Code, bool) {
+		if errors.Is(err, target) {
+			return code, true
+		}
+		return codes.Unknown, false
+	}
+}
+
+// ResolveErrorFunc returns a CodeResolver resolving errors for which match
+// returns true to code. It is meant for matching error types with errors.As.
+func ResolveErrorFunc(match func(err error) bool, code codes.Code) CodeResolver {
+	return func(err error) (codes.Code, bool) {
+		if match(err) {
+			return code, true
+		}
+		return codes.Unknown, false
+	}
+}
+
+// DefaultCodeResolver resolves the code of status errors, including wrapped
+// ones, with grpcstatus.FromError. It is always consulted after any
+// CodeResolver added to the metrics.
+func DefaultCodeResolver(err error) (codes.Code, bool) {
+	st, ok := grpcstatus.FromError(err)
+	return st.Code(), ok
+}
+
+// resolveCode returns the code of err from the first of the resolvers able to
+// resolve it, falling back to DefaultCodeResolver.
+func resolveCode(resolvers []CodeResolver, err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+	for _, resolver := range resolvers {
+		if code, ok := resolver(err); ok {
+			return code
+		}
+	}
+	code, _ := DefaultCodeResolver(err)
+	return code
+}
@@ -43,8 +43,8 @@ var (
 )
 
 func init() {
-	// Register standard server metrics and customized metrics to registry.
-	reg.MustRegister(grpcMetrics, customizedCounterMetric)
+	// Register customized metrics to registry.
+	reg.MustRegister(customizedCounterMetric)
 	customizedCounterMetric.WithLabelValues("Test")
 }
 
@@ -72,7 +72,9 @@ func main() {
 	// Register your service.
 	pb.RegisterDemoServiceServer(grpcServer, demoServer)
 
-	grpcMetrics.MustRegister()
+	// Register standard server metrics to registry.
+	grpcMetrics.MustRegister(reg)
+	grpcMetrics.InitializeMetrics(grpcServer)
 
 	// Start your http server for prometheus.
 	go func() {
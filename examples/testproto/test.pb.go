@@ -33,7 +33,7 @@ func (m *Empty) Reset()         { *m = Empty{} }
 func (m *Empty) String() string { return proto.CompactTextString(m) }
 func (*Empty) ProtoMessage()    {}
 func (*Empty) Descriptor() ([]byte, []int) {
-	return fileDescriptor_test_61863aae6603e9b8, []int{0}
+	return fileDescriptor_test_86ae22b0bc4197b8, []int{0}
 }
 func (m *Empty) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Empty.Unmarshal(m, b)
@@ -66,7 +66,7 @@ func (m *PingRequest) Reset()         { *m = PingRequest{} }
 func (m *PingRequest) String() string { return proto.CompactTextString(m) }
 func (*PingRequest) ProtoMessage()    {}
 func (*PingRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_test_61863aae6603e9b8, []int{1}
+	return fileDescriptor_test_86ae22b0bc4197b8, []int{1}
 }
 func (m *PingRequest) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_PingRequest.Unmarshal(m, b)
@@ -119,7 +119,7 @@ func (m *PingResponse) Reset()         { *m = PingResponse{} }
 func (m *PingResponse) String() string { return proto.CompactTextString(m) }
 func (*PingResponse) ProtoMessage()    {}
 func (*PingResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_test_61863aae6603e9b8, []int{2}
+	return fileDescriptor_test_86ae22b0bc4197b8, []int{2}
 }
 func (m *PingResponse) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_PingResponse.Unmarshal(m, b)
@@ -175,6 +175,7 @@ type TestServiceClient interface {
 	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error)
 	PingError(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*Empty, error)
 	PingList(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (TestService_PingListClient, error)
+	PingStream(ctx context.Context, opts ...grpc.CallOption) (TestService_PingStreamClient, error)
 }
 
 type testServiceClient struct {
@@ -244,12 +245,47 @@ func (x *testServicePingListClient) Recv() (*PingResponse, error) {
 	return m, nil
 }
 
+func (c *testServiceClient) PingStream(ctx context.Context, opts ...grpc.CallOption) (TestService_PingStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_TestService_serviceDesc.Streams[1], "/mwitkow.testproto.TestService/PingStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &testServicePingStreamClient{stream}
+	return x, nil
+}
+
+type TestService_PingStreamClient interface {
+	Send(*PingRequest) error
+	CloseAndRecv() (*PingResponse, error)
+	grpc.ClientStream
+}
+
+type testServicePingStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *testServicePingStreamClient) Send(m *PingRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *testServicePingStreamClient) CloseAndRecv() (*PingResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(PingResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // TestServiceServer is the server API for TestService service.
 type TestServiceServer interface {
 	PingEmpty(context.Context, *Empty) (*PingResponse, error)
 	Ping(context.Context, *PingRequest) (*PingResponse, error)
 	PingError(context.Context, *PingRequest) (*Empty, error)
 	PingList(*PingRequest, TestService_PingListServer) error
+	PingStream(TestService_PingStreamServer) error
 }
 
 func RegisterTestServiceServer(s *grpc.Server, srv TestServiceServer) {
@@ -331,6 +367,32 @@ func (x *testServicePingListServer) Send(m *PingResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _TestService_PingStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(TestServiceServer).PingStream(&testServicePingStreamServer{stream})
+}
+
+type TestService_PingStreamServer interface {
+	SendAndClose(*PingResponse) error
+	Recv() (*PingRequest, error)
+	grpc.ServerStream
+}
+
+type testServicePingStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *testServicePingStreamServer) SendAndClose(m *PingResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *testServicePingStreamServer) Recv() (*PingRequest, error) {
+	m := new(PingRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 var _TestService_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "mwitkow.testproto.TestService",
 	HandlerType: (*TestServiceServer)(nil),
@@ -354,30 +416,35 @@ var _TestService_serviceDesc = grpc.ServiceDesc{
 			Handler:       _TestService_PingList_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "PingStream",
+			Handler:       _TestService_PingStream_Handler,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "test.proto",
 }
 
-func init() { proto.RegisterFile("test.proto", fileDescriptor_test_61863aae6603e9b8) }
-
-var fileDescriptor_test_61863aae6603e9b8 = []byte{
-	// 274 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x50, 0x4d, 0x4b, 0xc3, 0x40,
-	0x10, 0x6d, 0xaa, 0xb1, 0x76, 0x62, 0x0f, 0x1d, 0x3d, 0x04, 0x0f, 0x1a, 0xf6, 0x94, 0x53, 0x10,
-	0xbd, 0x7b, 0x11, 0x51, 0x41, 0x51, 0x62, 0xf1, 0x1a, 0x34, 0x19, 0x64, 0xb1, 0x9b, 0x8d, 0xbb,
-	0x93, 0x06, 0xff, 0x9b, 0x3f, 0x4e, 0x76, 0x1b, 0xa1, 0xa0, 0x45, 0x0f, 0x3d, 0xbe, 0xf7, 0x86,
-	0xf7, 0x31, 0x00, 0x4c, 0x96, 0xb3, 0xc6, 0x68, 0xd6, 0x38, 0x55, 0x9d, 0xe4, 0x37, 0xdd, 0x65,
-	0x8e, 0xf3, 0x94, 0x18, 0x41, 0x78, 0xa9, 0x1a, 0xfe, 0x10, 0x1d, 0x44, 0x0f, 0xb2, 0x7e, 0xcd,
-	0xe9, 0xbd, 0x25, 0xcb, 0x78, 0x00, 0xe1, 0xe2, 0x79, 0xde, 0x52, 0x1c, 0x24, 0x41, 0x3a, 0xce,
-	0x97, 0x00, 0x05, 0x4c, 0xec, 0x9c, 0xa8, 0x29, 0x58, 0x2a, 0x2a, 0x94, 0x8d, 0x87, 0x49, 0x90,
-	0x86, 0x79, 0xe4, 0xc9, 0x99, 0x54, 0x74, 0x67, 0x31, 0x83, 0x7d, 0x32, 0x46, 0x9b, 0xa2, 0xd4,
-	0x15, 0x15, 0x86, 0xb8, 0x35, 0x35, 0x55, 0xf1, 0x56, 0x12, 0xa4, 0x93, 0x7c, 0xea, 0xa5, 0x0b,
-	0x5d, 0x51, 0xde, 0x0b, 0xe2, 0x1c, 0xf6, 0x96, 0xc1, 0xb6, 0xd1, 0xb5, 0x25, 0x97, 0xfc, 0xb4,
-	0x9a, 0xec, 0x01, 0xc6, 0x30, 0x2a, 0x75, 0x5b, 0x33, 0x99, 0x3e, 0xf3, 0x1b, 0x9e, 0x7e, 0x0e,
-	0x21, 0x9a, 0x91, 0xe5, 0x47, 0x32, 0x0b, 0x59, 0x12, 0x5e, 0xc3, 0xd8, 0xf9, 0xf9, 0x55, 0x18,
-	0x67, 0x3f, 0x26, 0x67, 0x5e, 0x39, 0x3c, 0xfe, 0x45, 0x59, 0xed, 0x21, 0x06, 0x78, 0x03, 0xdb,
-	0x8e, 0xc1, 0xa3, 0xb5, 0xa7, 0xfe, 0x57, 0xff, 0xb1, 0xba, 0xea, 0x4b, 0xb9, 0xf5, 0x7f, 0xfa,
-	0xad, 0x2d, 0x2d, 0x06, 0x78, 0x0f, 0xbb, 0xee, 0xf4, 0x56, 0x5a, 0xde, 0x40, 0xaf, 0x93, 0xe0,
-	0x65, 0xc7, 0xf3, 0x67, 0x5f, 0x01, 0x00, 0x00, 0xff, 0xff, 0xdb, 0x2f, 0xc7, 0x45, 0x28, 0x02,
-	0x00, 0x00,
+func init() { proto.RegisterFile("test.proto", fileDescriptor_test_86ae22b0bc4197b8) }
+
+var fileDescriptor_test_86ae22b0bc4197b8 = []byte{
+	// 284 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x90, 0xcf, 0x4b, 0xc3, 0x30,
+	0x14, 0xc7, 0x17, 0x67, 0x9d, 0x7b, 0x75, 0x87, 0x45, 0x0f, 0xc5, 0x83, 0x96, 0x9c, 0x7a, 0x2a,
+	0xa2, 0x77, 0x2f, 0x22, 0x2a, 0x28, 0x6a, 0x37, 0xbc, 0x96, 0xd9, 0x3e, 0x24, 0xb8, 0x34, 0x35,
+	0x79, 0x5d, 0xf1, 0xaf, 0xf0, 0x5f, 0x96, 0x64, 0x15, 0x06, 0x3a, 0xdc, 0x61, 0xc7, 0xf7, 0xf9,
+	0x86, 0xef, 0x8f, 0x00, 0x10, 0x5a, 0x4a, 0x6b, 0xa3, 0x49, 0xf3, 0xb1, 0x6a, 0x25, 0xbd, 0xeb,
+	0x36, 0x75, 0xcc, 0x23, 0x31, 0x80, 0xe0, 0x5a, 0xd5, 0xf4, 0x29, 0x5a, 0x08, 0x9f, 0x64, 0xf5,
+	0x96, 0xe1, 0x47, 0x83, 0x96, 0xf8, 0x11, 0x04, 0x8b, 0xd9, 0xbc, 0xc1, 0x88, 0xc5, 0x2c, 0x19,
+	0x66, 0xcb, 0x83, 0x0b, 0x18, 0xd9, 0x39, 0x62, 0x9d, 0x93, 0x54, 0x98, 0x2b, 0x1b, 0xed, 0xc4,
+	0x2c, 0x09, 0xb2, 0xd0, 0xc3, 0xa9, 0x54, 0xf8, 0x60, 0x79, 0x0a, 0x87, 0x68, 0x8c, 0x36, 0x79,
+	0xa1, 0x4b, 0xcc, 0x0d, 0x52, 0x63, 0x2a, 0x2c, 0xa3, 0x7e, 0xcc, 0x92, 0x51, 0x36, 0xf6, 0xd2,
+	0x95, 0x2e, 0x31, 0xeb, 0x04, 0x71, 0x09, 0x07, 0xcb, 0x60, 0x5b, 0xeb, 0xca, 0xa2, 0x4b, 0x7e,
+	0x59, 0x4d, 0xf6, 0x07, 0x8f, 0x60, 0x50, 0xe8, 0xa6, 0x22, 0x34, 0x5d, 0xe6, 0xcf, 0x79, 0xfe,
+	0xd5, 0x87, 0x70, 0x8a, 0x96, 0x26, 0x68, 0x16, 0xb2, 0x40, 0x7e, 0x0b, 0x43, 0xe7, 0xe7, 0x57,
+	0xf1, 0x28, 0xfd, 0x35, 0x39, 0xf5, 0xca, 0xf1, 0xe9, 0x1f, 0xca, 0x6a, 0x0f, 0xd1, 0xe3, 0x77,
+	0xb0, 0xeb, 0x08, 0x3f, 0x59, 0xfb, 0xd4, 0xff, 0xd5, 0x26, 0x56, 0x37, 0x5d, 0x29, 0xb7, 0xfe,
+	0x5f, 0xbf, 0xb5, 0xa5, 0x45, 0x8f, 0x3f, 0xc2, 0xbe, 0x7b, 0x7a, 0x2f, 0x2d, 0x6d, 0xa1, 0xd7,
+	0x19, 0xe3, 0xcf, 0x00, 0x8e, 0x4d, 0xc8, 0xe0, 0x4c, 0x6d, 0xc1, 0x32, 0x61, 0xaf, 0x7b, 0x9e,
+	0x5f, 0x7c, 0x0f, 0x00, 0x95, 0xbe, 0xb8, 0xc2, 0x7b, 0x02, 0x00, 0x00,
 }
@@ -0,0 +1,82 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"sync"
+)
+
+// labelBag holds the label values set by a handler during an RPC.
+type labelBag struct {
+	mu     sync.Mutex
+	values map[string]string
+}
+
+type labelBagKey struct{}
+
+// newLabelBagContext returns a copy of ctx carrying an empty label bag.
+func newLabelBagContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, labelBagKey{}, &labelBag{})
+}
+
+// SetLabel sets the value of a handler label of the current RPC. The value is
+// attached to the handled counter and the handling time histogram of the
+// RPC, if key was declared with WithHandlerLabels. It does nothing when ctx
+// doesn't belong to an RPC monitored by ServerMetrics with handler labels.
+// SetLabel is safe to call from multiple goroutines.
+func SetLabel(ctx context.Context, key, value string) {
+	bag, ok := ctx.Value(labelBagKey{}).(*labelBag)
+	if !ok {
+		return
+	}
+	bag.mu.Lock()
+	defer bag.mu.Unlock()
+	if bag.values == nil {
+		bag.values = map[string]string{}
+	}
+	bag.values[key] = value
+}
+
+// labelValues returns the values of the given keys set on the label bag of
+// ctx. Keys which were not set have empty values.
+func labelValues(ctx context.Context, keys []string) []string {
+	values := make([]string, len(keys))
+	bag, ok := ctx.Value(labelBagKey{}).(*labelBag)
+	if !ok {
+		return values
+	}
+	bag.mu.Lock()
+	defer bag.mu.Unlock()
+	for i, key := range keys {
+		values[i] = bag.values[key]
+	}
+	return values
+}
+
+// handlerLabelsExtension adds the declared handler labels to the handled
+// counter and histogram labels of the wrapped extension.
+type handlerLabelsExtension struct {
+	ServerExtension
+	keys []string
+}
+
+func (e handlerLabelsExtension) ServerHandledCounterCustomLabels() []string {
+	return concat(e.ServerExtension.ServerHandledCounterCustomLabels(), e.keys)
+}
+
+func (e handlerLabelsExtension) ServerHandledCounterValues(ctx context.Context) []string {
+	return concat(e.ServerExtension.ServerHandledCounterValues(ctx), labelValues(ctx, e.keys))
+}
+
+func (e handlerLabelsExtension) ServerHandledHistogramCustomLabels() []string {
+	return concat(e.ServerExtension.ServerHandledHistogramCustomLabels(), e.keys)
+}
+
+func (e handlerLabelsExtension) ServerHandledHistogramValues(ctx context.Context) []string {
+	return concat(e.ServerExtension.ServerHandledHistogramValues(ctx), labelValues(ctx, e.keys))
+}
+
+// concat returns a new slice holding a followed by b, leaving the backing
+// array of a, which may belong to another extension, untouched.
+func concat(a, b []string) []string {
+	return append(append(make([]string, 0, len(a)+len(b)), a...), b...)
+}
@@ -0,0 +1,156 @@
+package grpc_prometheus
+
+import (
+	"sync"
+	"sync/atomic"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+)
+
+// maxCachedMethods bounds the number of methods a methodCache holds, so that
+// servers accepting arbitrary method names, e.g. proxies using an unknown
+// service handler, don't grow it without limit. Methods beyond the limit are
+// still reported, just without caching.
+const maxCachedMethods = 1024
+
+// numCachedCodes is the number of codes, starting at codes.OK, for which
+// children are cached per method.
+const numCachedCodes = int(codes.Unauthenticated) + 1
+
+type methodKey struct {
+	rpcType    RPCType
+	fullMethod string
+}
+
+// methodCache maps methods to the cached children of their metrics. Lookups
+// are lock-free, the map is copied whenever a method is added.
+type methodCache struct {
+	mu      sync.Mutex
+	methods atomic.Value // map[methodKey]interface{}
+}
+
+func newMethodCache() *methodCache {
+	c := &methodCache{}
+	c.methods.Store(map[methodKey]interface{}{})
+	return c
+}
+
+// get returns the cached entry of the method, creating it with newEntry if
+// the method wasn't seen before.
+func (c *methodCache) get(rpcType RPCType, fullMethod string, newEntry func(rpcType RPCType, fullMethod string) interface{}) interface{} {
+	key := methodKey{rpcType, fullMethod}
+	if e, ok := c.methods.Load().(map[methodKey]interface{})[key]; ok {
+		return e
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	methods := c.methods.Load().(map[methodKey]interface{})
+	if e, ok := methods[key]; ok {
+		return e
+	}
+	e := newEntry(rpcType, fullMethod)
+	if len(methods) >= maxCachedMethods {
+		return e
+	}
+	updated := make(map[methodKey]interface{}, len(methods)+1)
+	for k, v := range methods {
+		updated[k] = v
+	}
+	updated[key] = e
+	c.methods.Store(updated)
+	return e
+}
+
+// lazyCounter is a child of a counter vector, resolved on first use so that
+// caching doesn't create series which were never incremented.
+type lazyCounter struct {
+	once    sync.Once
+	counter prom.Counter
+}
+
+// get returns the child with the given label values. The values are retained
+// by the vector, they must not be modified afterwards.
+func (l *lazyCounter) get(vec *prom.CounterVec, lvs []string) prom.Counter {
+	l.once.Do(func() { l.counter = vec.WithLabelValues(lvs...) })
+	return l.counter
+}
+
+// getWith returns the child with the given label values followed by value.
+func (l *lazyCounter) getWith(vec *prom.CounterVec, lvs []string, value string) prom.Counter {
+	l.once.Do(func() { l.counter = vec.WithLabelValues(append(lvs[:len(lvs):len(lvs)], value)...) })
+	return l.counter
+}
+
+// lazyObserver is a child of a histogram vector, resolved on first use.
+type lazyObserver struct {
+	once     sync.Once
+	observer prom.Observer
+}
+
+func (l *lazyObserver) get(h *methodHistogramVec, serviceName, fullMethod string, lvs []string) prom.Observer {
+	l.once.Do(func() { l.observer = h.forMethod(serviceName, fullMethod).WithLabelValues(lvs...) })
+	return l.observer
+}
+
+// serverMethodMetrics holds the cached children of the server metrics of a
+// method. Children of metrics with custom labels of the ServerExtension
+// depend on the context and are not cached.
+type serverMethodMetrics struct {
+	fullMethod  string
+	serviceName string
+	methodName  string
+	// labels are the grpc_type, grpc_service and grpc_method label values.
+	labels []string
+
+	settings         cachedSettings
+	started          lazyCounter
+	received         lazyCounter
+	sent             lazyCounter
+	panics           lazyCounter
+	handled          [numCachedCodes]lazyCounter
+	handledCodeClass [numCachedCodes]lazyCounter
+	handledHistogram lazyObserver
+	sloEvents        [len(allSLOResults)]lazyCounter
+}
+
+func newServerMethodMetrics(rpcType RPCType, fullMethod string) interface{} {
+	serviceName, methodName := SplitMethodName(fullMethod)
+	return &serverMethodMetrics{
+		fullMethod:  fullMethod,
+		serviceName: serviceName,
+		methodName:  methodName,
+		labels:      []string{string(rpcType), serviceName, methodName},
+	}
+}
+
+// clientMethodMetrics holds the cached children of the client metrics of a
+// method.
+type clientMethodMetrics struct {
+	fullMethod  string
+	serviceName string
+	methodName  string
+	// labels are the grpc_type, grpc_service and grpc_method label values.
+	labels []string
+
+	settings         cachedSettings
+	started          lazyCounter
+	received         lazyCounter
+	sent             lazyCounter
+	handled          [numCachedCodes]lazyCounter
+	handledCodeClass [numCachedCodes]lazyCounter
+	handledHistogram lazyObserver
+	recvHistogram    lazyObserver
+	sendHistogram    lazyObserver
+}
+
+func newClientMethodMetrics(rpcType RPCType, fullMethod string) interface{} {
+	serviceName, methodName := SplitMethodName(fullMethod)
+	return &clientMethodMetrics{
+		fullMethod:  fullMethod,
+		serviceName: serviceName,
+		methodName:  methodName,
+		labels:      []string{string(rpcType), serviceName, methodName},
+	}
+}
@@ -0,0 +1,44 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestMethodCache(t *testing.T) {
+	c := newMethodCache()
+	first := c.get(Unary, "/mwitkow.testproto.TestService/Ping", newServerMethodMetrics)
+	require.True(t, first == c.get(Unary, "/mwitkow.testproto.TestService/Ping", newServerMethodMetrics), "entries must be cached")
+	require.False(t, first == c.get(ServerStream, "/mwitkow.testproto.TestService/Ping", newServerMethodMetrics), "entries must be cached per type")
+
+	for i := 0; i < maxCachedMethods; i++ {
+		c.get(Unary, fmt.Sprintf("/unknown.Service/Method%d", i), newServerMethodMetrics)
+	}
+	require.Len(t, c.methods.Load().(map[methodKey]interface{}), maxCachedMethods, "cache must be bounded")
+	entry := c.get(Unary, "/unknown.Service/Uncached", newServerMethodMetrics).(*serverMethodMetrics)
+	require.Equal(t, []string{"unary", "unknown.Service", "Uncached"}, entry.labels, "uncached methods must still be reported")
+}
+
+func TestMethodCacheCreatesOnlyUsedSeries(t *testing.T) {
+	m := NewServerMetrics()
+	r := newServerReporter(m, Unary, "/mwitkow.testproto.TestService/Ping")
+	r.Started(context.TODO())
+	r.Handled(context.TODO(), codes.OK, 0)
+
+	require.Equal(t, 1, countSeries(m.serverHandledCounter), "no series must be created for codes not handled")
+	require.Equal(t, 0, countSeries(m.serverStreamMsgSentCounter), "no series must be created for messages not sent")
+	requireValue(t, 1, m.serverStartedCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+	requireValue(t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "OK"))
+}
+
+func countSeries(c prometheus.Collector) int {
+	ch := make(chan prometheus.Metric, 100)
+	c.Collect(ch)
+	close(ch)
+	return len(ch)
+}
@@ -0,0 +1,81 @@
+package grpc_prometheus
+
+import (
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// methodHistogramVec is a histogram vector whose buckets can be overridden
+// per service or method. As buckets are fixed per vector, it is backed by a
+// vector for every override, all sharing the name of the histogram.
+type methodHistogramVec struct {
+	family     MetricFamily
+	defaultVec *prom.HistogramVec
+	// overrides maps service names and full method names to their vectors.
+	overrides map[string]*prom.HistogramVec
+}
+
+func newMethodHistogramVec(opts prom.HistogramOpts, labels []string, bucketOverrides map[string][]float64) *methodHistogramVec {
+	h := &methodHistogramVec{
+		family:     newMetricFamily(opts.Namespace, opts.Subsystem, opts.Name, labels),
+		defaultVec: prom.NewHistogramVec(opts, labels),
+		overrides:  make(map[string]*prom.HistogramVec, len(bucketOverrides)),
+	}
+	for key, buckets := range bucketOverrides {
+		o := opts
+		o.Buckets = buckets
+		h.overrides[key] = prom.NewHistogramVec(o, labels)
+	}
+	return h
+}
+
+// forMethod returns the vector of the method, falling back from the full
+// method name to the service name and the default vector.
+func (h *methodHistogramVec) forMethod(serviceName, fullMethod string) *prom.HistogramVec {
+	if len(h.overrides) == 0 {
+		return h.defaultVec
+	}
+	if vec, ok := h.overrides[fullMethod]; ok {
+		return vec
+	}
+	if vec, ok := h.overrides[serviceName]; ok {
+		return vec
+	}
+	return h.defaultVec
+}
+
+// Describe implements prom.Collector. The vectors share their descriptors,
+// which the registry allows within a collector.
+func (h *methodHistogramVec) Describe(ch chan<- *prom.Desc) {
+	h.defaultVec.Describe(ch)
+	for _, vec := range h.overrides {
+		vec.Describe(ch)
+	}
+}
+
+// Collect implements prom.Collector.
+func (h *methodHistogramVec) Collect(ch chan<- prom.Metric) {
+	h.defaultVec.Collect(ch)
+	for _, vec := range h.overrides {
+		vec.Collect(ch)
+	}
+}
+
+// Reset deletes all metrics of all vectors.
+func (h *methodHistogramVec) Reset() {
+	h.defaultVec.Reset()
+	for _, vec := range h.overrides {
+		vec.Reset()
+	}
+}
+
+// copyBucketOverrides returns a copy of overrides with additional applied.
+func copyBucketOverrides(overrides, additional map[string][]float64) map[string][]float64 {
+	merged := make(map[string][]float64, len(overrides)+len(additional))
+	for key, buckets := range overrides {
+		merged[key] = buckets
+	}
+	for key, buckets := range additional {
+		merged[key] = buckets
+	}
+	return merged
+}
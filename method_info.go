@@ -0,0 +1,130 @@
+package grpc_prometheus
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+)
+
+// A MethodOptionLabel is a proto method option added as a label to
+// grpc_server_method_info by WithMethodInfo.
+type MethodOptionLabel string
+
+const (
+	// IdempotencyLevelLabel is the idempotency_level method option, e.g.
+	// "NO_SIDE_EFFECTS".
+	IdempotencyLevelLabel MethodOptionLabel = "idempotency_level"
+	// DeprecatedLabel is the deprecated method option, "true" or "false".
+	DeprecatedLabel MethodOptionLabel = "deprecated"
+)
+
+// WithMethodInfo enables the grpc_server_method_info gauge, set to 1 by
+// InitializeMetrics for each registered method, with the grpc_type,
+// grpc_service, grpc_method, client_streaming and server_streaming labels
+// followed by the given method option labels. The options are read from the
+// file descriptors registered by the generated code of the services; the
+// labels of methods without a descriptor, or of unknown options, are empty.
+// The gauge is meant to be joined on, e.g. to find deprecated methods still
+// receiving traffic.
+func WithMethodInfo(optionLabels ...MethodOptionLabel) ServerMetricsOption {
+	return serverMetricsOptionFunc(func(o *serverMetricsOptions) {
+		o.methodInfo = true
+		o.methodOptionLabels = append(o.methodOptionLabels, optionLabels...)
+	})
+}
+
+func newMethodInfoGauge(families metricFamilies, opts counterOptions, optionLabels []MethodOptionLabel) *prom.GaugeVec {
+	labels := []string{"grpc_type", "grpc_service", "grpc_method", "client_streaming", "server_streaming"}
+	for _, l := range optionLabels {
+		labels = append(labels, string(l))
+	}
+	return families.gaugeVec(
+		prom.GaugeOpts(opts.apply(prom.CounterOpts{
+			Name: "grpc_server_method_info",
+			Help: "Information about the methods registered on the server, always 1.",
+		})), labels)
+}
+
+// setMethodInfo sets the info gauge of the method. metadata is the metadata
+// of the grpc.ServiceInfo of the service.
+func (m *ServerMetrics) setMethodInfo(serviceName string, metadata interface{}, mInfo *grpc.MethodInfo, descriptors methodDescriptors) {
+	lvs := []string{
+		string(typeFromMethodInfo(mInfo)), serviceName, mInfo.Name,
+		strconv.FormatBool(mInfo.IsClientStream), strconv.FormatBool(mInfo.IsServerStream),
+	}
+	desc := descriptors.method(metadata, serviceName, mInfo.Name)
+	for _, l := range m.methodOptionLabels {
+		lvs = append(lvs, methodOptionValue(desc, l))
+	}
+	m.serverMethodInfo.WithLabelValues(lvs...).Set(1)
+}
+
+func methodOptionValue(desc *descriptor.MethodDescriptorProto, label MethodOptionLabel) string {
+	if desc == nil {
+		return ""
+	}
+	switch label {
+	case IdempotencyLevelLabel:
+		return desc.GetOptions().GetIdempotencyLevel().String()
+	case DeprecatedLabel:
+		return strconv.FormatBool(desc.GetOptions().GetDeprecated())
+	}
+	return ""
+}
+
+// methodDescriptors maps the full method names of the services of the
+// decoded proto files to their descriptors, keyed by file name.
+type methodDescriptors map[string]map[string]*descriptor.MethodDescriptorProto
+
+// method returns the descriptor of the method from the file named by
+// metadata, the grpc.ServiceInfo metadata of generated services, or nil.
+func (d methodDescriptors) method(metadata interface{}, serviceName, methodName string) *descriptor.MethodDescriptorProto {
+	fileName, ok := metadata.(string)
+	if !ok {
+		return nil
+	}
+	methods, ok := d[fileName]
+	if !ok {
+		methods = decodeMethodDescriptors(fileName)
+		d[fileName] = methods
+	}
+	return methods["/"+serviceName+"/"+methodName]
+}
+
+// decodeMethodDescriptors decodes the methods of the registered proto file,
+// returning nil if it isn't registered or can't be decoded.
+func decodeMethodDescriptors(fileName string) map[string]*descriptor.MethodDescriptorProto {
+	gz := proto.FileDescriptor(fileName)
+	if gz == nil {
+		return nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		return nil
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil
+	}
+	file := &descriptor.FileDescriptorProto{}
+	if err := proto.Unmarshal(b, file); err != nil {
+		return nil
+	}
+	methods := map[string]*descriptor.MethodDescriptorProto{}
+	for _, service := range file.GetService() {
+		serviceName := service.GetName()
+		if file.GetPackage() != "" {
+			serviceName = file.GetPackage() + "." + serviceName
+		}
+		for _, method := range service.GetMethod() {
+			methods["/"+serviceName+"/"+method.GetName()] = method
+		}
+	}
+	return methods
+}
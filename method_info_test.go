@@ -0,0 +1,68 @@
+package grpc_prometheus
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func init() {
+	file := &descriptor.FileDescriptorProto{
+		Name:    proto.String("grpc_prometheus/method_info_test.proto"),
+		Package: proto.String("grpc_prometheus.test"),
+		Service: []*descriptor.ServiceDescriptorProto{{
+			Name: proto.String("LegacyService"),
+			Method: []*descriptor.MethodDescriptorProto{{
+				Name: proto.String("Get"),
+				Options: &descriptor.MethodOptions{
+					Deprecated:       proto.Bool(true),
+					IdempotencyLevel: descriptor.MethodOptions_NO_SIDE_EFFECTS.Enum(),
+				},
+			}},
+		}},
+	}
+	b, err := proto.Marshal(file)
+	if err != nil {
+		panic(err)
+	}
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(b)
+	w.Close()
+	proto.RegisterFile(file.GetName(), gz.Bytes())
+}
+
+var legacyServiceDesc = grpc.ServiceDesc{
+	ServiceName: "grpc_prometheus.test.LegacyService",
+	HandlerType: (*interface{})(nil),
+	Methods:     []grpc.MethodDesc{{MethodName: "Get"}},
+	Metadata:    "grpc_prometheus/method_info_test.proto",
+}
+
+func TestServerMethodInfo(t *testing.T) {
+	m := NewServerMetricsWithOptions(WithMethodInfo(IdempotencyLevelLabel, DeprecatedLabel))
+	reg := prometheus.NewPedanticRegistry()
+	m.MustRegister(reg)
+	server := grpc.NewServer()
+	pb_testproto.RegisterTestServiceServer(server, &testService{t: t})
+	server.RegisterService(&legacyServiceDesc, struct{}{})
+	m.InitializeMetrics(server)
+
+	requireValue(t, 1, m.serverMethodInfo.WithLabelValues("unary", "grpc_prometheus.test.LegacyService", "Get", "false", "false", "NO_SIDE_EFFECTS", "true"))
+	requireValue(t, 1, m.serverMethodInfo.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList", "false", "true", "IDEMPOTENCY_UNKNOWN", "false"))
+	require.Equal(t, 6, countSeries(m.serverMethodInfo), "all methods must have an info series")
+	require.Contains(t, gatherNames(t, reg), "grpc_server_method_info")
+
+	reg = prometheus.NewPedanticRegistry()
+	m = NewServerMetrics()
+	m.MustRegister(reg)
+	m.InitializeMetrics(server)
+	require.NotContains(t, gatherNames(t, reg), "grpc_server_method_info", "the info gauge must be optional")
+}
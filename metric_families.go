@@ -0,0 +1,54 @@
+package grpc_prometheus
+
+import (
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// MetricFamily describes a metric collected by ServerMetrics or
+// ClientMetrics, for tooling generating queries, rules or dashboards.
+type MetricFamily struct {
+	// Name is the fully-qualified name of the metric, including any
+	// namespace and subsystem.
+	Name string
+	// Labels are the variable labels of the metric, including extension and
+	// handler labels.
+	Labels []string
+}
+
+func newMetricFamily(namespace, subsystem, name string, labels []string) MetricFamily {
+	return MetricFamily{
+		Name:   prom.BuildFQName(namespace, subsystem, name),
+		Labels: append([]string(nil), labels...),
+	}
+}
+
+// metricFamilies records the families of the vectors of ServerMetrics or
+// ClientMetrics as they are created. The client library doesn't expose the
+// names and labels of its collectors.
+type metricFamilies map[prom.Collector]MetricFamily
+
+func (f metricFamilies) counterVec(opts prom.CounterOpts, labels []string) *prom.CounterVec {
+	vec := prom.NewCounterVec(opts, labels)
+	f[vec] = newMetricFamily(opts.Namespace, opts.Subsystem, opts.Name, labels)
+	return vec
+}
+
+func (f metricFamilies) gaugeVec(opts prom.GaugeOpts, labels []string) *prom.GaugeVec {
+	vec := prom.NewGaugeVec(opts, labels)
+	f[vec] = newMetricFamily(opts.Namespace, opts.Subsystem, opts.Name, labels)
+	return vec
+}
+
+// list returns the families of the collectors, in order.
+func (f metricFamilies) list(collectors []prom.Collector) []MetricFamily {
+	families := make([]MetricFamily, 0, len(collectors))
+	for _, c := range collectors {
+		family := f[c]
+		if h, ok := c.(*methodHistogramVec); ok {
+			family = h.family
+		}
+		family.Labels = append([]string(nil), family.Labels...)
+		families = append(families, family)
+	}
+	return families
+}
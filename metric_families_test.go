@@ -0,0 +1,43 @@
+package grpc_prometheus
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerMetricFamilies(t *testing.T) {
+	m := NewServerMetricsWithOptions(
+		WithCounterOptions(func(o *prometheus.CounterOpts) { o.Namespace = "myapp" }),
+		WithHandlerLabels("cache_hit"),
+		WithHandlingTimeHistogram(func(o *prometheus.HistogramOpts) { o.Namespace = "myapp" }),
+		WithMethodInfo(DeprecatedLabel),
+	)
+	require.Equal(t, []MetricFamily{
+		{Name: "myapp_grpc_server_started_total", Labels: []string{"grpc_type", "grpc_service", "grpc_method"}},
+		{Name: "myapp_grpc_server_handled_total", Labels: []string{"cache_hit", "grpc_type", "grpc_service", "grpc_method", "grpc_code"}},
+		{Name: "myapp_grpc_server_msg_received_total", Labels: []string{"grpc_type", "grpc_service", "grpc_method"}},
+		{Name: "myapp_grpc_server_msg_sent_total", Labels: []string{"grpc_type", "grpc_service", "grpc_method"}},
+		{Name: "myapp_grpc_server_handling_seconds", Labels: []string{"cache_hit", "grpc_type", "grpc_service", "grpc_method"}},
+		{Name: "myapp_grpc_server_method_info", Labels: []string{"grpc_type", "grpc_service", "grpc_method", "client_streaming", "server_streaming", "deprecated"}},
+	}, m.MetricFamilies())
+
+	m.MetricFamilies()[0].Labels[0] = "modified"
+	require.Equal(t, "grpc_type", m.MetricFamilies()[0].Labels[0], "the families must not be modifiable")
+}
+
+func TestClientMetricFamilies(t *testing.T) {
+	m := NewClientMetricsWithOptions(WithClientHandlingTimeHistogram())
+	var names []string
+	for _, f := range m.MetricFamilies() {
+		names = append(names, f.Name)
+	}
+	require.Equal(t, []string{
+		"grpc_client_started_total",
+		"grpc_client_handled_total",
+		"grpc_client_msg_received_total",
+		"grpc_client_msg_sent_total",
+		"grpc_client_handling_seconds",
+	}, names)
+}
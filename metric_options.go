@@ -23,6 +23,17 @@ func WithConstLabels(labels prom.Labels) CounterOption {
 	}
 }
 
+// WithCounterOptions combines counter options into a single CounterOption,
+// which can be passed to NewServerMetricsWithOptions and
+// NewClientMetricsWithOptions along with their other options.
+func WithCounterOptions(opts ...CounterOption) CounterOption {
+	return func(o *prom.CounterOpts) {
+		for _, opt := range opts {
+			opt(o)
+		}
+	}
+}
+
 // A HistogramOption lets you add options to Histogram metrics using With*
 // funcs.
 type HistogramOption func(*prom.HistogramOpts)
@@ -0,0 +1,116 @@
+// Package admin serves an HTTP API changing the runtime settings of
+// ServerMetrics and ClientMetrics, e.g. to enable histograms during an
+// incident without a redeploy:
+//
+//	http.Handle("/grpc-metrics/", http.StripPrefix("/grpc-metrics", admin.NewHandler(map[string]grpc_prometheus.RuntimeSettings{
+//		"server": serverMetrics,
+//		"client": clientMetrics,
+//	})))
+//
+// The API is:
+//
+//	GET    /                                list the settings of all metrics
+//	GET    /{metrics}                       list the settings of the metrics
+//	PUT    /{metrics}/histograms/{name}     enable a histogram
+//	DELETE /{metrics}/histograms/{name}     disable a histogram
+//	PUT    /{metrics}/methods?method={m}    set the MethodSettings, given as JSON, of a service or full method name
+//	DELETE /{metrics}/methods?method={m}    restore the default settings of a service or full method name
+//
+// Settings are returned as JSON. Changes respond with the updated settings
+// of the metrics. The handler changes what is collected; it should only be
+// reachable by operators.
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+)
+
+type handler struct {
+	metrics map[string]grpc_prometheus.RuntimeSettings
+}
+
+// NewHandler returns a handler serving the settings of the given metrics,
+// keyed by the name used in the paths of the API.
+func NewHandler(metrics map[string]grpc_prometheus.RuntimeSettings) http.Handler {
+	return &handler{metrics: metrics}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] == "" {
+		if !allowMethods(w, r, http.MethodGet) {
+			return
+		}
+		all := make(map[string]grpc_prometheus.Settings, len(h.metrics))
+		for name, m := range h.metrics {
+			all[name] = m.Settings()
+		}
+		writeJSON(w, all)
+		return
+	}
+
+	m, ok := h.metrics[parts[0]]
+	if !ok {
+		http.Error(w, "unknown metrics "+parts[0], http.StatusNotFound)
+		return
+	}
+	switch {
+	case len(parts) == 1:
+		if !allowMethods(w, r, http.MethodGet) {
+			return
+		}
+	case len(parts) == 3 && parts[1] == "histograms":
+		if !allowMethods(w, r, http.MethodPut, http.MethodDelete) {
+			return
+		}
+		err := m.SetHistogramEnabled(parts[2], r.Method == http.MethodPut)
+		if errors.Is(err, grpc_prometheus.ErrUnknownHistogram) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+	case len(parts) == 2 && parts[1] == "methods":
+		if !allowMethods(w, r, http.MethodPut, http.MethodDelete) {
+			return
+		}
+		method := r.URL.Query().Get("method")
+		if method == "" {
+			http.Error(w, "missing method parameter", http.StatusBadRequest)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			m.DeleteMethodSettings(method)
+			break
+		}
+		var settings grpc_prometheus.MethodSettings
+		if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+			http.Error(w, "invalid method settings: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		m.SetMethodSettings(method, settings)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, m.Settings())
+}
+
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
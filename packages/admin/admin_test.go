@@ -0,0 +1,64 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	server := grpc_prometheus.NewServerMetrics()
+	handler := NewHandler(map[string]grpc_prometheus.RuntimeSettings{"server": server})
+
+	rec := serve(handler, http.MethodPut, "/server/histograms/grpc_server_handling_seconds", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.True(t, server.Settings().Histograms["grpc_server_handling_seconds"])
+
+	rec = serve(handler, http.MethodPut, "/server/methods?method=/mwitkow.testproto.TestService/Ping", `{"no_histograms": true}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var settings grpc_prometheus.Settings
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &settings))
+	require.Equal(t, grpc_prometheus.MethodSettings{NoHistograms: true}, settings.Methods["/mwitkow.testproto.TestService/Ping"])
+
+	rec = serve(handler, http.MethodGet, "/", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var all map[string]grpc_prometheus.Settings
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &all))
+	require.Equal(t, server.Settings(), all["server"])
+
+	rec = serve(handler, http.MethodDelete, "/server/methods?method=/mwitkow.testproto.TestService/Ping", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotContains(t, server.Settings().Methods, "/mwitkow.testproto.TestService/Ping")
+	rec = serve(handler, http.MethodDelete, "/server/histograms/grpc_server_handling_seconds", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.False(t, server.Settings().Histograms["grpc_server_handling_seconds"])
+}
+
+func TestHandlerErrors(t *testing.T) {
+	handler := NewHandler(map[string]grpc_prometheus.RuntimeSettings{"server": grpc_prometheus.NewServerMetrics()})
+	for _, tc := range []struct {
+		method, target, body string
+		code                 int
+	}{
+		{http.MethodGet, "/client", "", http.StatusNotFound},
+		{http.MethodPut, "/server/histograms/grpc_server_unknown_seconds", "", http.StatusNotFound},
+		{http.MethodPost, "/server/histograms/grpc_server_handling_seconds", "", http.StatusMethodNotAllowed},
+		{http.MethodPut, "/server/methods", "{}", http.StatusBadRequest},
+		{http.MethodPut, "/server/methods?method=/a/b", "not json", http.StatusBadRequest},
+		{http.MethodGet, "/server/other", "", http.StatusNotFound},
+	} {
+		rec := serve(handler, tc.method, tc.target, tc.body)
+		require.Equal(t, tc.code, rec.Code, "%s %s", tc.method, tc.target)
+	}
+}
+
+func serve(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec
+}
@@ -0,0 +1,310 @@
+// Package channelzmetrics exports the channelz data of grpc-go to
+// Prometheus: the call counts and connectivity states of channels,
+// subchannels and servers, and optionally the stream and message counts of
+// sockets. It gives transport-level visibility alongside ServerMetrics and
+// ClientMetrics.
+//
+// The collector reads channelz through the channelz service, which also
+// turns channelz on, e.g. registered on the server of the process:
+//
+//	service.RegisterChannelzServiceToServer(server)
+//	conn, err := grpc.Dial(serverAddr, grpc.WithInsecure())
+//	...
+//	prometheus.MustRegister(channelzmetrics.NewCollector(grpc_channelz_v1.NewChannelzClient(conn)))
+//
+// The calls of the collector to the channelz service are visible in
+// channelz too.
+//
+// Socket metrics are labelled with the id and the local and remote addresses
+// of the socket, so they add series for every connection, which a busy
+// server or a client reconnecting often accumulates quickly. They are only
+// collected with WithSocketMetrics.
+package channelzmetrics
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	channelzpb "google.golang.org/grpc/channelz/grpc_channelz_v1"
+)
+
+// DefaultTimeout is the default time a scrape may spend reading channelz.
+const DefaultTimeout = 10 * time.Second
+
+// An Option configures a Collector.
+type Option func(*Collector)
+
+// WithTimeout sets the time a scrape may spend reading channelz.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Collector) {
+		c.timeout = timeout
+	}
+}
+
+// WithSocketMetrics collects the stream, message and keep alive counters of
+// every socket. As sockets are labelled by their id and addresses, this adds
+// series for every connection of the process.
+func WithSocketMetrics() Option {
+	return func(c *Collector) {
+		c.sockets = true
+	}
+}
+
+// callDescs describes the call counters of channels, subchannels or servers.
+type callDescs struct {
+	started, succeeded, failed *prom.Desc
+}
+
+func newCallDescs(entity string, labels []string) callDescs {
+	return callDescs{
+		started: prom.NewDesc("grpc_channelz_"+entity+"_calls_started_total",
+			"Total number of calls started on the "+entity+".", labels, nil),
+		succeeded: prom.NewDesc("grpc_channelz_"+entity+"_calls_succeeded_total",
+			"Total number of calls completed with OK on the "+entity+".", labels, nil),
+		failed: prom.NewDesc("grpc_channelz_"+entity+"_calls_failed_total",
+			"Total number of calls completed with a non-OK status on the "+entity+".", labels, nil),
+	}
+}
+
+// Collector is a prometheus.Collector walking channelz at scrape time.
+type Collector struct {
+	client  channelzpb.ChannelzClient
+	timeout time.Duration
+	sockets bool
+
+	channelCalls     callDescs
+	channelState     *prom.Desc
+	subchannelCalls  callDescs
+	subchannelState  *prom.Desc
+	serverCalls      callDescs
+	socketStreams    callDescs
+	socketMsgSent    *prom.Desc
+	socketMsgRecv    *prom.Desc
+	socketKeepAlives *prom.Desc
+}
+
+// NewCollector returns a Collector reading channelz with client.
+func NewCollector(client channelzpb.ChannelzClient, opts ...Option) *Collector {
+	socketLabels := []string{"socket_id", "local", "remote"}
+	c := &Collector{
+		client:          client,
+		timeout:         DefaultTimeout,
+		channelCalls:    newCallDescs("channel", []string{"channel_id", "target"}),
+		subchannelCalls: newCallDescs("subchannel", []string{"subchannel_id", "target"}),
+		serverCalls:     newCallDescs("server", []string{"server_id"}),
+		channelState: prom.NewDesc("grpc_channelz_channel_state",
+			"Connectivity state of the channel, 1 for the current state.", []string{"channel_id", "target", "state"}, nil),
+		subchannelState: prom.NewDesc("grpc_channelz_subchannel_state",
+			"Connectivity state of the subchannel, 1 for the current state.", []string{"subchannel_id", "target", "state"}, nil),
+		socketStreams: callDescs{
+			started: prom.NewDesc("grpc_channelz_socket_streams_started_total",
+				"Total number of streams started on the socket.", socketLabels, nil),
+			succeeded: prom.NewDesc("grpc_channelz_socket_streams_succeeded_total",
+				"Total number of streams ended with an end of stream on the socket.", socketLabels, nil),
+			failed: prom.NewDesc("grpc_channelz_socket_streams_failed_total",
+				"Total number of streams ended without an end of stream on the socket.", socketLabels, nil),
+		},
+		socketMsgSent: prom.NewDesc("grpc_channelz_socket_messages_sent_total",
+			"Total number of messages sent on the socket.", socketLabels, nil),
+		socketMsgRecv: prom.NewDesc("grpc_channelz_socket_messages_received_total",
+			"Total number of messages received on the socket.", socketLabels, nil),
+		socketKeepAlives: prom.NewDesc("grpc_channelz_socket_keepalives_sent_total",
+			"Total number of keep alives sent on the socket.", socketLabels, nil),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prom.Desc) {
+	calls := []callDescs{c.channelCalls, c.subchannelCalls, c.serverCalls}
+	if c.sockets {
+		calls = append(calls, c.socketStreams)
+	}
+	for _, descs := range calls {
+		ch <- descs.started
+		ch <- descs.succeeded
+		ch <- descs.failed
+	}
+	ch <- c.channelState
+	ch <- c.subchannelState
+	if c.sockets {
+		ch <- c.socketMsgSent
+		ch <- c.socketMsgRecv
+		ch <- c.socketKeepAlives
+	}
+}
+
+// Collect implements prometheus.Collector. Failing to read channelz fails
+// the scrape.
+func (c *Collector) Collect(ch chan<- prom.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	s := &scrape{Collector: c, ctx: ctx, ch: ch, seenSockets: map[int64]bool{}}
+	if err := s.collectChannels(); err != nil {
+		ch <- prom.NewInvalidMetric(c.channelCalls.started, err)
+		return
+	}
+	if err := s.collectServers(); err != nil {
+		ch <- prom.NewInvalidMetric(c.serverCalls.started, err)
+	}
+}
+
+// scrape holds the state of a single Collect.
+type scrape struct {
+	*Collector
+	ctx context.Context
+	ch  chan<- prom.Metric
+	// seenSockets avoids collecting sockets twice, which channelz may
+	// reference from several entities.
+	seenSockets map[int64]bool
+}
+
+func (s *scrape) collectChannels() error {
+	var start int64
+	for {
+		resp, err := s.client.GetTopChannels(s.ctx, &channelzpb.GetTopChannelsRequest{StartChannelId: start})
+		if err != nil {
+			return err
+		}
+		for _, channel := range resp.GetChannel() {
+			if err := s.collectChannel(channel); err != nil {
+				return err
+			}
+			start = channel.GetRef().GetChannelId() + 1
+		}
+		if resp.GetEnd() || len(resp.GetChannel()) == 0 {
+			return nil
+		}
+	}
+}
+
+// collectChannel collects the channel, and its child channels, subchannels
+// and sockets.
+func (s *scrape) collectChannel(channel *channelzpb.Channel) error {
+	id := strconv.FormatInt(channel.GetRef().GetChannelId(), 10)
+	data := channel.GetData()
+	s.collectCalls(s.channelCalls, data.GetCallsStarted(), data.GetCallsSucceeded(), data.GetCallsFailed(), id, data.GetTarget())
+	s.ch <- prom.MustNewConstMetric(s.channelState, prom.GaugeValue, 1, id, data.GetTarget(), data.GetState().GetState().String())
+	return s.collectChildren(channel.GetChannelRef(), channel.GetSubchannelRef(), channel.GetSocketRef())
+}
+
+func (s *scrape) collectSubchannel(subchannel *channelzpb.Subchannel) error {
+	id := strconv.FormatInt(subchannel.GetRef().GetSubchannelId(), 10)
+	data := subchannel.GetData()
+	s.collectCalls(s.subchannelCalls, data.GetCallsStarted(), data.GetCallsSucceeded(), data.GetCallsFailed(), id, data.GetTarget())
+	s.ch <- prom.MustNewConstMetric(s.subchannelState, prom.GaugeValue, 1, id, data.GetTarget(), data.GetState().GetState().String())
+	return s.collectChildren(subchannel.GetChannelRef(), subchannel.GetSubchannelRef(), subchannel.GetSocketRef())
+}
+
+func (s *scrape) collectChildren(channels []*channelzpb.ChannelRef, subchannels []*channelzpb.SubchannelRef, sockets []*channelzpb.SocketRef) error {
+	for _, ref := range channels {
+		resp, err := s.client.GetChannel(s.ctx, &channelzpb.GetChannelRequest{ChannelId: ref.GetChannelId()})
+		if err != nil {
+			return err
+		}
+		if err := s.collectChannel(resp.GetChannel()); err != nil {
+			return err
+		}
+	}
+	for _, ref := range subchannels {
+		resp, err := s.client.GetSubchannel(s.ctx, &channelzpb.GetSubchannelRequest{SubchannelId: ref.GetSubchannelId()})
+		if err != nil {
+			return err
+		}
+		if err := s.collectSubchannel(resp.GetSubchannel()); err != nil {
+			return err
+		}
+	}
+	return s.collectSockets(sockets)
+}
+
+func (s *scrape) collectServers() error {
+	var start int64
+	for {
+		resp, err := s.client.GetServers(s.ctx, &channelzpb.GetServersRequest{StartServerId: start})
+		if err != nil {
+			return err
+		}
+		for _, server := range resp.GetServer() {
+			serverID := server.GetRef().GetServerId()
+			data := server.GetData()
+			s.collectCalls(s.serverCalls, data.GetCallsStarted(), data.GetCallsSucceeded(), data.GetCallsFailed(), strconv.FormatInt(serverID, 10))
+			if s.sockets {
+				if err := s.collectServerSockets(serverID); err != nil {
+					return err
+				}
+			}
+			start = serverID + 1
+		}
+		if resp.GetEnd() || len(resp.GetServer()) == 0 {
+			return nil
+		}
+	}
+}
+
+func (s *scrape) collectServerSockets(serverID int64) error {
+	var start int64
+	for {
+		resp, err := s.client.GetServerSockets(s.ctx, &channelzpb.GetServerSocketsRequest{ServerId: serverID, StartSocketId: start})
+		if err != nil {
+			return err
+		}
+		if err := s.collectSockets(resp.GetSocketRef()); err != nil {
+			return err
+		}
+		refs := resp.GetSocketRef()
+		if resp.GetEnd() || len(refs) == 0 {
+			return nil
+		}
+		start = refs[len(refs)-1].GetSocketId() + 1
+	}
+}
+
+func (s *scrape) collectSockets(refs []*channelzpb.SocketRef) error {
+	if !s.sockets {
+		return nil
+	}
+	for _, ref := range refs {
+		if s.seenSockets[ref.GetSocketId()] {
+			continue
+		}
+		s.seenSockets[ref.GetSocketId()] = true
+		resp, err := s.client.GetSocket(s.ctx, &channelzpb.GetSocketRequest{SocketId: ref.GetSocketId()})
+		if err != nil {
+			return err
+		}
+		socket := resp.GetSocket()
+		data := socket.GetData()
+		lvs := []string{strconv.FormatInt(ref.GetSocketId(), 10), formatAddress(socket.GetLocal()), formatAddress(socket.GetRemote())}
+		s.collectCalls(s.socketStreams, data.GetStreamsStarted(), data.GetStreamsSucceeded(), data.GetStreamsFailed(), lvs...)
+		s.ch <- prom.MustNewConstMetric(s.socketMsgSent, prom.CounterValue, float64(data.GetMessagesSent()), lvs...)
+		s.ch <- prom.MustNewConstMetric(s.socketMsgRecv, prom.CounterValue, float64(data.GetMessagesReceived()), lvs...)
+		s.ch <- prom.MustNewConstMetric(s.socketKeepAlives, prom.CounterValue, float64(data.GetKeepAlivesSent()), lvs...)
+	}
+	return nil
+}
+
+func (s *scrape) collectCalls(descs callDescs, started, succeeded, failed int64, lvs ...string) {
+	s.ch <- prom.MustNewConstMetric(descs.started, prom.CounterValue, float64(started), lvs...)
+	s.ch <- prom.MustNewConstMetric(descs.succeeded, prom.CounterValue, float64(succeeded), lvs...)
+	s.ch <- prom.MustNewConstMetric(descs.failed, prom.CounterValue, float64(failed), lvs...)
+}
+
+// formatAddress formats a channelz address like net.Addr.String does.
+func formatAddress(addr *channelzpb.Address) string {
+	switch a := addr.GetAddress().(type) {
+	case *channelzpb.Address_TcpipAddress:
+		return net.JoinHostPort(net.IP(a.TcpipAddress.GetIpAddress()).String(), strconv.Itoa(int(a.TcpipAddress.GetPort())))
+	case *channelzpb.Address_UdsAddress_:
+		return a.UdsAddress.GetFilename()
+	case *channelzpb.Address_OtherAddress_:
+		return a.OtherAddress.GetName()
+	}
+	return ""
+}
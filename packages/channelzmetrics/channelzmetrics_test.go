@@ -0,0 +1,98 @@
+package channelzmetrics
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	channelzpb "google.golang.org/grpc/channelz/grpc_channelz_v1"
+	"google.golang.org/grpc/channelz/service"
+)
+
+type testService struct {
+	pb_testproto.TestServiceServer
+}
+
+func (testService) Ping(_ context.Context, req *pb_testproto.PingRequest) (*pb_testproto.PingResponse, error) {
+	return &pb_testproto.PingResponse{Value: req.Value}, nil
+}
+
+func TestCollector(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	pb_testproto.RegisterTestServiceServer(server, testService{})
+	service.RegisterChannelzServiceToServer(server)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	for i := 0; i < 3; i++ {
+		_, err := pb_testproto.NewTestServiceClient(conn).Ping(context.Background(), &pb_testproto.PingRequest{Value: "channelz"})
+		require.NoError(t, err)
+	}
+
+	families := gather(t, NewCollector(channelzpb.NewChannelzClient(conn), WithSocketMetrics()))
+
+	channel := findMetric(families["grpc_channelz_channel_calls_started_total"], "target", lis.Addr().String())
+	require.NotNil(t, channel, "the channel must be collected")
+	require.True(t, channel.GetCounter().GetValue() >= 3, "got %v", channel.GetCounter().GetValue())
+	require.NotNil(t, findMetric(families["grpc_channelz_channel_state"], "state", "READY"))
+	require.NotNil(t, findMetric(families["grpc_channelz_subchannel_state"], "state", "READY"))
+	require.Contains(t, families, "grpc_channelz_server_calls_succeeded_total")
+
+	socket := findMetric(families["grpc_channelz_socket_messages_sent_total"], "remote", lis.Addr().String())
+	require.NotNil(t, socket, "the client socket must be collected")
+	require.True(t, socket.GetCounter().GetValue() >= 3, "got %v", socket.GetCounter().GetValue())
+	require.NotNil(t, findMetric(families["grpc_channelz_socket_streams_started_total"], "local", lis.Addr().String()),
+		"the server socket must be collected")
+
+	families = gather(t, NewCollector(channelzpb.NewChannelzClient(conn)))
+	require.Contains(t, families, "grpc_channelz_channel_calls_started_total")
+	for name := range families {
+		require.NotContains(t, name, "socket", "sockets must only be collected with WithSocketMetrics")
+	}
+}
+
+// gather returns the metric families collected by c by name.
+func gather(t *testing.T, c prom.Collector) map[string]*dto.MetricFamily {
+	reg := prom.NewPedanticRegistry()
+	reg.MustRegister(c)
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	families := map[string]*dto.MetricFamily{}
+	for _, mf := range mfs {
+		families[mf.GetName()] = mf
+	}
+	return families
+}
+
+func TestCollectorUnavailable(t *testing.T) {
+	conn, err := grpc.Dial("127.0.0.1:1", grpc.WithInsecure())
+	require.NoError(t, err)
+	conn.Close()
+
+	reg := prom.NewRegistry()
+	reg.MustRegister(NewCollector(channelzpb.NewChannelzClient(conn)))
+	_, err = reg.Gather()
+	require.Error(t, err, "failing to read channelz must fail the scrape")
+}
+
+// findMetric returns the metric of the family with the given label value.
+func findMetric(mf *dto.MetricFamily, name, value string) *dto.Metric {
+	for _, m := range mf.GetMetric() {
+		for _, lp := range m.GetLabel() {
+			if lp.GetName() == name && lp.GetValue() == value {
+				return m
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Package expvarmetrics reports gRPC RPCs as expvar variables, for programs
+// exposing /debug/vars rather than Prometheus metrics. Its reporters plug
+// into the reporter interceptors of grpc_prometheus:
+//
+//	m := expvarmetrics.New("grpc_server")
+//	grpc.NewServer(
+//		grpc.UnaryInterceptor(grpc_prometheus.UnaryServerInterceptorWithReporter(m.NewServerReporter)),
+//		grpc.StreamInterceptor(grpc_prometheus.StreamServerInterceptorWithReporter(m.NewServerReporter)),
+//	)
+package expvarmetrics
+
+import (
+	"context"
+	"expvar"
+	"sync"
+	"time"
+
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"google.golang.org/grpc/codes"
+)
+
+// Metrics holds the variables of the reported RPCs in an expvar.Map keyed by
+// the full method name. The map of a method holds the counters started,
+// msg_received, msg_sent and handled_<code>, and handling_seconds, the total
+// time spent handling its RPCs.
+type Metrics struct {
+	mu      sync.Mutex
+	methods *expvar.Map
+}
+
+// New returns Metrics published under name. Like expvar.Publish, it panics
+// if name is already in use.
+func New(name string) *Metrics {
+	return &Metrics{methods: expvar.NewMap(name)}
+}
+
+// Map returns the map of the reported methods.
+func (m *Metrics) Map() *expvar.Map {
+	return m.methods
+}
+
+// NewServerReporter is a grpc_prometheus.ServerReporterFactory reporting
+// server RPCs.
+func (m *Metrics) NewServerReporter(_ context.Context, _ grpc_prometheus.RPCType, fullMethod string) grpc_prometheus.ServerReporter {
+	return serverReporter{m.method(fullMethod)}
+}
+
+// NewClientReporter is a grpc_prometheus.ClientReporterFactory reporting
+// client RPCs.
+func (m *Metrics) NewClientReporter(_ context.Context, _ grpc_prometheus.RPCType, fullMethod string) grpc_prometheus.ClientReporter {
+	return clientReporter{m.method(fullMethod)}
+}
+
+func (m *Metrics) method(fullMethod string) method {
+	if vars, ok := m.methods.Get(fullMethod).(*expvar.Map); ok {
+		return method{vars}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	vars, ok := m.methods.Get(fullMethod).(*expvar.Map)
+	if !ok {
+		vars = new(expvar.Map).Init()
+		m.methods.Set(fullMethod, vars)
+	}
+	return method{vars}
+}
+
+// method records the events of a method.
+type method struct {
+	vars *expvar.Map
+}
+
+func (m method) handled(code codes.Code, elapsed time.Duration) {
+	m.vars.Add("handled_"+code.String(), 1)
+	m.vars.AddFloat("handling_seconds", elapsed.Seconds())
+}
+
+type serverReporter struct{ method }
+
+func (r serverReporter) Started(context.Context)         { r.vars.Add("started", 1) }
+func (r serverReporter) ReceivedMessage(context.Context) { r.vars.Add("msg_received", 1) }
+func (r serverReporter) SentMessage(context.Context)     { r.vars.Add("msg_sent", 1) }
+func (r serverReporter) Handled(_ context.Context, code codes.Code, elapsed time.Duration) {
+	r.handled(code, elapsed)
+}
+
+type clientReporter struct{ method }
+
+func (r clientReporter) Started() { r.vars.Add("started", 1) }
+
+func (r clientReporter) ReceivedMessage(err error, _ time.Duration) {
+	if err == nil {
+		r.vars.Add("msg_received", 1)
+	}
+}
+
+func (r clientReporter) SentMessage(err error, _ time.Duration) {
+	if err == nil {
+		r.vars.Add("msg_sent", 1)
+	}
+}
+
+func (r clientReporter) Handled(code codes.Code, elapsed time.Duration) {
+	r.handled(code, elapsed)
+}
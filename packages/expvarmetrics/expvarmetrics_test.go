@@ -0,0 +1,51 @@
+package expvarmetrics
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestServerReporter(t *testing.T) {
+	m := New("test_grpc_server")
+	interceptor := grpc_prometheus.UnaryServerInterceptorWithReporter(m.NewServerReporter)
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"}
+	interceptor(context.TODO(), nil, info, func(context.Context, interface{}) (interface{}, error) { return nil, nil })
+	interceptor(context.TODO(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "not found")
+	})
+
+	vars := decode(t, m)["/mwitkow.testproto.TestService/Ping"]
+	require.EqualValues(t, 2, vars["started"])
+	require.EqualValues(t, 2, vars["msg_received"])
+	require.EqualValues(t, 1, vars["msg_sent"])
+	require.EqualValues(t, 1, vars["handled_OK"])
+	require.EqualValues(t, 1, vars["handled_NotFound"])
+	require.Contains(t, vars, "handling_seconds")
+}
+
+func TestClientReporter(t *testing.T) {
+	m := New("test_grpc_client")
+	grpc_prometheus.UnaryClientInterceptorWithReporter(m.NewClientReporter)(context.TODO(), "/mwitkow.testproto.TestService/Ping", nil, nil, nil,
+		func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+			return status.Error(codes.Unavailable, "connection refused")
+		})
+
+	vars := decode(t, m)["/mwitkow.testproto.TestService/Ping"]
+	require.EqualValues(t, 1, vars["started"])
+	require.EqualValues(t, 1, vars["msg_sent"])
+	require.NotContains(t, vars, "msg_received", "failed calls must not report a received message")
+	require.EqualValues(t, 1, vars["handled_Unavailable"])
+}
+
+func decode(t *testing.T, m *Metrics) map[string]map[string]float64 {
+	var methods map[string]map[string]float64
+	require.NoError(t, json.Unmarshal([]byte(m.Map().String()), &methods))
+	return methods
+}
@@ -0,0 +1,322 @@
+// Package grafana generates Grafana dashboards for the services registered on
+// a gRPC server, using the exact metric names and labels produced by
+// ServerMetrics and ClientMetrics.
+package grafana
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/grpc-ecosystem/go-grpc-prometheus/packages/metricdesc"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+)
+
+// Options configures the generated dashboard.
+type Options struct {
+	// Title of the dashboard.
+	Title string
+	// ServerMetrics are the metrics the services are instrumented with on
+	// the server side. Server panels are omitted if nil.
+	ServerMetrics prom.Collector
+	// ClientMetrics are the metrics the services are instrumented with on
+	// the client side. Client panels are omitted if nil.
+	ClientMetrics prom.Collector
+}
+
+type dashboard struct {
+	Title         string     `json:"title"`
+	Tags          []string   `json:"tags"`
+	Editable      bool       `json:"editable"`
+	SchemaVersion int        `json:"schemaVersion"`
+	Time          timeRange  `json:"time"`
+	Templating    templating `json:"templating"`
+	Panels        []panel    `json:"panels"`
+}
+
+type timeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type templating struct {
+	List []variable `json:"list"`
+}
+
+type variable struct {
+	Name       string      `json:"name"`
+	Label      string      `json:"label"`
+	Type       string      `json:"type"`
+	Query      interface{} `json:"query"`
+	Datasource *datasource `json:"datasource,omitempty"`
+	Refresh    int         `json:"refresh,omitempty"`
+	Multi      bool        `json:"multi,omitempty"`
+	IncludeAll bool        `json:"includeAll,omitempty"`
+}
+
+type datasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type gridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type target struct {
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat,omitempty"`
+	Format       string `json:"format,omitempty"`
+	RefID        string `json:"refId"`
+}
+
+type fieldConfig struct {
+	Defaults fieldDefaults `json:"defaults"`
+}
+
+type fieldDefaults struct {
+	Unit string `json:"unit,omitempty"`
+}
+
+type panel struct {
+	ID          int          `json:"id"`
+	Type        string       `json:"type"`
+	Title       string       `json:"title"`
+	GridPos     gridPos      `json:"gridPos"`
+	Datasource  *datasource  `json:"datasource,omitempty"`
+	Targets     []target     `json:"targets,omitempty"`
+	FieldConfig *fieldConfig `json:"fieldConfig,omitempty"`
+}
+
+var promDatasource = &datasource{Type: "prometheus", UID: "${datasource}"}
+
+// Dashboard returns the JSON model of a Grafana dashboard with a row per
+// service and side, and per method panels showing the request rate by code,
+// the ratio of server errors, the latency heatmap if the handling time
+// histogram is enabled, the message rates and the number of in-flight RPCs.
+// services is typically the result of grpc.Server.GetServiceInfo.
+func Dashboard(services map[string]grpc.ServiceInfo, opts Options) ([]byte, error) {
+	if opts.ServerMetrics == nil && opts.ClientMetrics == nil {
+		return nil, fmt.Errorf("grafana: no server or client metrics given")
+	}
+	title := opts.Title
+	if title == "" {
+		title = "gRPC"
+	}
+	d := dashboard{
+		Title:         title,
+		Tags:          []string{"grpc"},
+		Editable:      true,
+		SchemaVersion: 36,
+		Time:          timeRange{From: "now-1h", To: "now"},
+		Templating: templating{List: []variable{
+			{Name: "datasource", Label: "Data source", Type: "datasource", Query: "prometheus"},
+			{
+				Name:       "job",
+				Label:      "Job",
+				Type:       "query",
+				Datasource: promDatasource,
+				Refresh:    1,
+				Multi:      true,
+				IncludeAll: true,
+			},
+		}},
+	}
+
+	var serviceNames []string
+	for name := range services {
+		serviceNames = append(serviceNames, name)
+	}
+	sort.Strings(serviceNames)
+
+	b := &builder{}
+	for _, side := range []struct {
+		name    string
+		title   string
+		metrics prom.Collector
+	}{{"server", "Server", opts.ServerMetrics}, {"client", "Client", opts.ClientMetrics}} {
+		if side.metrics == nil {
+			continue
+		}
+		m, err := newSideMetrics(side.name, side.metrics)
+		if err != nil {
+			return nil, err
+		}
+		if d.Templating.List[1].Query == nil {
+			d.Templating.List[1].Query = fmt.Sprintf("label_values(%s, job)", m.started.Name)
+		}
+		for _, serviceName := range serviceNames {
+			b.row(fmt.Sprintf("%s %s", side.title, serviceName))
+			methods := append([]grpc.MethodInfo(nil), services[serviceName].Methods...)
+			sort.Slice(methods, func(i, j int) bool { return methods[i].Name < methods[j].Name })
+			for _, method := range methods {
+				b.methodPanels(m, serviceName, method.Name)
+			}
+		}
+	}
+	d.Panels = b.panels
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// sideMetrics are the families of the server or client metrics the panels
+// query.
+type sideMetrics struct {
+	started    metricdesc.Family
+	handled    metricdesc.Family
+	received   metricdesc.Family
+	sent       metricdesc.Family
+	histogram  metricdesc.Family
+	histograms bool
+}
+
+func newSideMetrics(side string, c prom.Collector) (*sideMetrics, error) {
+	families, err := metricdesc.Describe(c)
+	if err != nil {
+		return nil, err
+	}
+	m := &sideMetrics{}
+	for _, f := range []struct {
+		family *metricdesc.Family
+		name   string
+	}{
+		{&m.started, "started_total"},
+		{&m.handled, "handled_total"},
+		{&m.received, "msg_received_total"},
+		{&m.sent, "msg_sent_total"},
+	} {
+		name := fmt.Sprintf("grpc_%s_%s", side, f.name)
+		family, ok := metricdesc.Find(families, name)
+		if !ok {
+			return nil, fmt.Errorf("grafana: %s metrics don't describe %s", side, name)
+		}
+		*f.family = family
+	}
+	m.histogram, m.histograms = metricdesc.Find(families, fmt.Sprintf("grpc_%s_handling_seconds", side))
+	return m, nil
+}
+
+type builder struct {
+	panels []panel
+	y      int
+}
+
+func (b *builder) add(p panel) {
+	p.ID = len(b.panels) + 1
+	b.panels = append(b.panels, p)
+}
+
+func (b *builder) row(title string) {
+	b.add(panel{Type: "row", Title: title, GridPos: gridPos{H: 1, W: 24, Y: b.y}})
+	b.y++
+}
+
+func (b *builder) methodPanels(m *sideMetrics, service, method string) {
+	sel := fmt.Sprintf(`job=~"$job",grpc_service=%q,grpc_method=%q`, service, method)
+	type spec struct {
+		title   string
+		kind    string
+		unit    string
+		targets []target
+	}
+	specs := []spec{
+		{
+			title: fmt.Sprintf("%s: RPS by code", method),
+			kind:  "timeseries",
+			unit:  "reqps",
+			targets: []target{{
+				Expr:         fmt.Sprintf("sum by (grpc_code) (rate(%s{%s}[$__rate_interval]))", m.handled.Name, sel),
+				LegendFormat: "{{grpc_code}}",
+			}},
+		},
+		{
+			title: fmt.Sprintf("%s: error ratio", method),
+			kind:  "timeseries",
+			unit:  "percentunit",
+			targets: []target{{
+				Expr: fmt.Sprintf("sum(rate(%s{%s,grpc_code=~%q}[$__rate_interval]))\n/\nsum(rate(%s{%s}[$__rate_interval]))",
+					m.handled.Name, sel, metricdesc.CodeClassRegexp(grpc_prometheus.CodeClassServerError), m.handled.Name, sel),
+				LegendFormat: "server errors",
+			}},
+		},
+	}
+	if m.histograms {
+		specs = append(specs, spec{
+			title: fmt.Sprintf("%s: latency", method),
+			kind:  "heatmap",
+			unit:  "s",
+			targets: []target{{
+				Expr:         fmt.Sprintf("sum by (le) (rate(%s_bucket{%s}[$__rate_interval]))", m.histogram.Name, sel),
+				LegendFormat: "{{le}}",
+				Format:       "heatmap",
+			}},
+		})
+	}
+	specs = append(specs,
+		spec{
+			title: fmt.Sprintf("%s: messages", method),
+			kind:  "timeseries",
+			unit:  "ops",
+			targets: []target{
+				{Expr: fmt.Sprintf("sum(rate(%s{%s}[$__rate_interval]))", m.received.Name, sel), LegendFormat: "received"},
+				{Expr: fmt.Sprintf("sum(rate(%s{%s}[$__rate_interval]))", m.sent.Name, sel), LegendFormat: "sent"},
+			},
+		},
+		spec{
+			title: fmt.Sprintf("%s: in-flight", method),
+			kind:  "timeseries",
+			unit:  "short",
+			targets: []target{{
+				Expr:         fmt.Sprintf("sum(%s{%s}) - sum(%s{%s})", m.started.Name, sel, m.handled.Name, sel),
+				LegendFormat: "in-flight",
+			}},
+		},
+	)
+
+	width := 24 / len(specs)
+	for i, s := range specs {
+		for j := range s.targets {
+			s.targets[j].RefID = string(rune('A' + j))
+		}
+		b.add(panel{
+			Type:        s.kind,
+			Title:       s.title,
+			GridPos:     gridPos{H: 8, W: width, X: i * width, Y: b.y},
+			Datasource:  promDatasource,
+			Targets:     s.targets,
+			FieldConfig: &fieldConfig{Defaults: fieldDefaults{Unit: s.unit}},
+		})
+	}
+	b.y += 8
+}
+
+// ServicesFromFileDescriptors returns the services defined in the given proto
+// file descriptors, in the form returned by grpc.Server.GetServiceInfo. It
+// allows generating dashboards without starting the server, e.g. from a
+// descriptor set produced by protoc --descriptor_set_out.
+func ServicesFromFileDescriptors(files ...*descriptor.FileDescriptorProto) map[string]grpc.ServiceInfo {
+	services := make(map[string]grpc.ServiceInfo)
+	for _, file := range files {
+		for _, service := range file.GetService() {
+			name := service.GetName()
+			if file.GetPackage() != "" {
+				name = file.GetPackage() + "." + name
+			}
+			info := grpc.ServiceInfo{Metadata: file.GetName()}
+			for _, method := range service.GetMethod() {
+				info.Methods = append(info.Methods, grpc.MethodInfo{
+					Name:           method.GetName(),
+					IsClientStream: method.GetClientStreaming(),
+					IsServerStream: method.GetServerStreaming(),
+				})
+			}
+			services[name] = info
+		}
+	}
+	return services
+}
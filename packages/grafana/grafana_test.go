@@ -0,0 +1,121 @@
+package grafana
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// testService is only registered for its service info, it is never served.
+type testService struct {
+	pb_testproto.TestServiceServer
+}
+
+type parsedDashboard struct {
+	Title  string `json:"title"`
+	Panels []struct {
+		Type    string `json:"type"`
+		Title   string `json:"title"`
+		Targets []struct {
+			Expr string `json:"expr"`
+		} `json:"targets"`
+	} `json:"panels"`
+}
+
+func TestDashboard(t *testing.T) {
+	server := grpc.NewServer()
+	pb_testproto.RegisterTestServiceServer(server, &testService{})
+
+	serverMetrics := grpc_prometheus.NewServerMetricsWithOptions(
+		grpc_prometheus.CounterOption(func(o *prom.CounterOpts) { o.Namespace = "myapp" }),
+		grpc_prometheus.WithHandlingTimeHistogram(func(o *prom.HistogramOpts) { o.Namespace = "myapp" }),
+	)
+	clientMetrics := grpc_prometheus.NewClientMetrics()
+
+	raw, err := Dashboard(server.GetServiceInfo(), Options{
+		Title:         "Test service",
+		ServerMetrics: serverMetrics,
+		ClientMetrics: clientMetrics,
+	})
+	require.NoError(t, err)
+
+	var d parsedDashboard
+	require.NoError(t, json.Unmarshal(raw, &d))
+	require.Equal(t, "Test service", d.Title)
+
+	var rows, heatmaps int
+	var exprs []string
+	for _, p := range d.Panels {
+		switch p.Type {
+		case "row":
+			rows++
+		case "heatmap":
+			heatmaps++
+		}
+		for _, target := range p.Targets {
+			exprs = append(exprs, target.Expr)
+		}
+	}
+	require.Equal(t, 2, rows, "one row per service and side")
+	require.Equal(t, 5, heatmaps, "latency heatmaps only for server methods, as only the server histogram is enabled")
+	// 5 methods with 5 server panels and 4 client panels each, plus the rows.
+	require.Len(t, d.Panels, 2+5*5+5*4)
+
+	all := strings.Join(exprs, "\n")
+	require.Contains(t, all, `myapp_grpc_server_handled_total{job=~"$job",grpc_service="mwitkow.testproto.TestService",grpc_method="PingList"}`)
+	require.Contains(t, all, `myapp_grpc_server_handling_seconds_bucket{job=~"$job",grpc_service="mwitkow.testproto.TestService",grpc_method="Ping"}`)
+	require.Contains(t, all, `grpc_client_msg_received_total{job=~"$job",grpc_service="mwitkow.testproto.TestService",grpc_method="PingEmpty"}`)
+	require.NotContains(t, all, "grpc_client_handling_seconds")
+}
+
+func TestDashboardKeepsServices(t *testing.T) {
+	services := map[string]grpc.ServiceInfo{
+		"mwitkow.testproto.TestService": {Methods: []grpc.MethodInfo{{Name: "PingList"}, {Name: "Ping"}}},
+	}
+	_, err := Dashboard(services, Options{ServerMetrics: grpc_prometheus.NewServerMetrics()})
+	require.NoError(t, err)
+	require.Equal(t, []grpc.MethodInfo{{Name: "PingList"}, {Name: "Ping"}}, services["mwitkow.testproto.TestService"].Methods,
+		"the methods of the caller must not be reordered")
+}
+
+func TestDashboardUncheckedMetrics(t *testing.T) {
+	services := map[string]grpc.ServiceInfo{"mwitkow.testproto.TestService": {Methods: []grpc.MethodInfo{{Name: "Ping"}}}}
+	_, err := Dashboard(services, Options{
+		ServerMetrics: grpc_prometheus.NewServerMetricsWithOptions(grpc_prometheus.WithUncheckedCollector()),
+		ClientMetrics: grpc_prometheus.NewClientMetricsWithOptions(grpc_prometheus.WithClientUncheckedCollector()),
+	})
+	require.NoError(t, err, "unchecked metrics must not need descriptors")
+}
+
+func TestDashboardRequiresMetrics(t *testing.T) {
+	_, err := Dashboard(nil, Options{})
+	require.Error(t, err)
+}
+
+func TestServicesFromFileDescriptors(t *testing.T) {
+	gz, err := gzip.NewReader(bytes.NewReader(proto.FileDescriptor("test.proto")))
+	require.NoError(t, err)
+	b, err := ioutil.ReadAll(gz)
+	require.NoError(t, err)
+	file := &descriptor.FileDescriptorProto{}
+	require.NoError(t, proto.Unmarshal(b, file))
+
+	server := grpc.NewServer()
+	pb_testproto.RegisterTestServiceServer(server, &testService{})
+	expected := server.GetServiceInfo()
+
+	services := ServicesFromFileDescriptors(file)
+	require.Len(t, services, 1)
+	require.ElementsMatch(t, expected["mwitkow.testproto.TestService"].Methods, services["mwitkow.testproto.TestService"].Methods)
+}
@@ -1,6 +1,8 @@
 package grpcstatus
 
 import (
+	"context"
+
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
@@ -30,10 +32,72 @@ func unwrapPkgErrorsGRPCStatus(err error) (*status.Status, bool) {
 	return nil, false
 }
 
+// walkErrorTree calls visit for err and all errors it wraps, depth first, until
+// visit returns true. It follows the `github.com/pkg/errors` causer interface as
+// well as the native `Unwrap() error` and multi-error `Unwrap() []error`
+// interfaces, the latter being implemented by `errors.Join` results.
+func walkErrorTree(err error, visit func(error) bool) bool {
+	type causer interface {
+		Cause() error
+	}
+	type wrapper interface {
+		Unwrap() error
+	}
+	type multiWrapper interface {
+		Unwrap() []error
+	}
+
+	if err == nil {
+		return false
+	}
+	if visit(err) {
+		return true
+	}
+	switch e := err.(type) {
+	case causer:
+		return walkErrorTree(e.Cause(), visit)
+	case wrapper:
+		return walkErrorTree(e.Unwrap(), visit)
+	case multiWrapper:
+		for _, wrapped := range e.Unwrap() {
+			if walkErrorTree(wrapped, visit) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func unwrapErrorTreeGRPCStatus(err error) (s *status.Status, ok bool) {
+	ok = walkErrorTree(err, func(e error) bool {
+		if st, isStatus := e.(gRPCStatus); isStatus {
+			s = st.GRPCStatus()
+			return true
+		}
+		return false
+	})
+	return s, ok
+}
+
+// contextErrorStatus maps context.DeadlineExceeded and context.Canceled found in
+// the error tree to their gRPC status, the way gRPC does for RPCs that ran out of
+// time or were cancelled.
+func contextErrorStatus(err error) (s *status.Status, ok bool) {
+	ok = walkErrorTree(err, func(e error) bool {
+		if e == context.DeadlineExceeded || e == context.Canceled {
+			s = status.New(status.FromContextError(e).Code(), err.Error())
+			return true
+		}
+		return false
+	})
+	return s, ok
+}
+
 // Since error can be wrapped and the `FromError` function only checks for `GRPCStatus` function
 // and as a fallback uses the `Unknown` gRPC status we need to unwrap the error if possible to get the original status.
 // pkg/errors and Go native errors packages have two different approaches so we try to unwrap both types.
 // Eventually should be implemented in the go-grpc status function `FromError`. See https://github.com/grpc/grpc-go/issues/2934
+// Multi-errors are searched depth first, and raw or wrapped context errors map to `DeadlineExceeded` and `Canceled`.
 func FromError(err error) (s *status.Status, ok bool) {
 	s, ok = status.FromError(err)
 	if ok {
@@ -52,6 +116,19 @@ func FromError(err error) (s *status.Status, ok bool) {
 		return s, true
 	}
 
+	// Try to find a GRPCStatus in multi-errors such as `errors.Join` results,
+	// or in chains mixing both of the wrapping approaches above
+	s, ok = unwrapErrorTreeGRPCStatus(err)
+	if ok {
+		return s, true
+	}
+
+	// Map context errors returned as-is or wrapped by handlers
+	s, ok = contextErrorStatus(err)
+	if ok {
+		return s, true
+	}
+
 	// We failed to unwrap any GRPSStatus so return default `Unknown`
 	return status.New(codes.Unknown, err.Error()), false
 }
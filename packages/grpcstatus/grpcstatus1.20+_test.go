@@ -0,0 +1,34 @@
+//go:build go1.20
+// +build go1.20
+
+package grpcstatus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestJoinedErrorUnwrapping(t *testing.T) {
+	gRPCError := status.Errorf(codes.FailedPrecondition, "Userspace error.")
+	expectedGRPCStatus, _ := status.FromError(gRPCError)
+	testedErrors := []error{
+		errors.Join(errors.New("other error"), gRPCError),
+		fmt.Errorf("go native wrapped error: %w", errors.Join(errors.New("other error"), gRPCError)),
+	}
+
+	for _, e := range testedErrors {
+		resultingStatus, ok := FromError(e)
+		require.True(t, ok)
+		require.Equal(t, expectedGRPCStatus, resultingStatus)
+	}
+
+	resultingStatus, ok := FromError(fmt.Errorf("handler failed: %w", errors.Join(errors.New("other error"), context.DeadlineExceeded)))
+	require.True(t, ok)
+	require.Equal(t, codes.DeadlineExceeded, resultingStatus.Code())
+}
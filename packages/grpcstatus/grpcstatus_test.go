@@ -1,6 +1,9 @@
 package grpcstatus
 
 import (
+	"context"
+	"errors"
+
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -17,6 +20,13 @@ func (w *wrappedError) Error() string { return w.msg + ": " + w.cause.Error() }
 
 func (w *wrappedError) Cause() error { return w.cause }
 
+// Multi-error implementing the same interface as errors.Join results
+type multiError []error
+
+func (m multiError) Error() string { return "multiple errors" }
+
+func (m multiError) Unwrap() []error { return m }
+
 func TestErrorUnwrapping(t *testing.T) {
 	gRPCCode := codes.FailedPrecondition
 	gRPCError := status.Errorf(gRPCCode, "Userspace error.")
@@ -32,3 +42,42 @@ func TestErrorUnwrapping(t *testing.T) {
 		require.Equal(t, expectedGRPCStatus, resultingStatus)
 	}
 }
+
+func TestMultiErrorUnwrapping(t *testing.T) {
+	gRPCCode := codes.FailedPrecondition
+	gRPCError := status.Errorf(gRPCCode, "Userspace error.")
+	expectedGRPCStatus, _ := status.FromError(gRPCError)
+	testedErrors := []error{
+		multiError{gRPCError},
+		multiError{errors.New("other error"), gRPCError},
+		&wrappedError{cause: multiError{errors.New("other error"), gRPCError}, msg: "pkg/errors wrapped error: "},
+		multiError{context.Canceled, gRPCError},
+	}
+
+	for _, e := range testedErrors {
+		resultingStatus, ok := FromError(e)
+		require.True(t, ok)
+		require.Equal(t, expectedGRPCStatus, resultingStatus)
+	}
+}
+
+func TestContextErrorMapping(t *testing.T) {
+	for _, testCase := range []struct {
+		err          error
+		expectedCode codes.Code
+	}{
+		{context.DeadlineExceeded, codes.DeadlineExceeded},
+		{context.Canceled, codes.Canceled},
+		{&wrappedError{cause: context.DeadlineExceeded, msg: "pkg/errors wrapped error: "}, codes.DeadlineExceeded},
+		{multiError{errors.New("other error"), context.Canceled}, codes.Canceled},
+	} {
+		resultingStatus, ok := FromError(testCase.err)
+		require.True(t, ok)
+		require.Equal(t, testCase.expectedCode, resultingStatus.Code())
+		require.Equal(t, testCase.err.Error(), resultingStatus.Message())
+	}
+
+	resultingStatus, ok := FromError(errors.New("other error"))
+	require.False(t, ok)
+	require.Equal(t, codes.Unknown, resultingStatus.Code())
+}
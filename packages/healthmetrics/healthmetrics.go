@@ -0,0 +1,138 @@
+// Package healthmetrics exports the serving status of the services of a
+// grpc-go health server as Prometheus metrics, so that health flapping shows
+// up next to the RPC metrics of grpc_prometheus:
+//
+//	hs := healthmetrics.NewServer(health.NewServer())
+//	healthpb.RegisterHealthServer(server, hs)
+//	prometheus.MustRegister(hs)
+//	...
+//	hs.SetServingStatus("mwitkow.testproto.TestService", healthpb.HealthCheckResponse_NOT_SERVING)
+package healthmetrics
+
+import (
+	"context"
+	"sync"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// servingStatuses are the statuses of a service, initialized for each
+// service.
+var servingStatuses = []healthpb.HealthCheckResponse_ServingStatus{
+	healthpb.HealthCheckResponse_UNKNOWN,
+	healthpb.HealthCheckResponse_SERVING,
+	healthpb.HealthCheckResponse_NOT_SERVING,
+	healthpb.HealthCheckResponse_SERVICE_UNKNOWN,
+}
+
+// Server wraps a health.Server, recording the changes of the serving status
+// made through SetServingStatus, Shutdown and Resume. It implements the
+// health service and prometheus.Collector.
+//
+// Statuses set on the wrapped server directly are picked up at scrape time
+// for the services the Server knows of, and counted as a single change even
+// if the status changed several times between scrapes.
+type Server struct {
+	*health.Server
+
+	mu       sync.Mutex
+	statuses map[string]healthpb.HealthCheckResponse_ServingStatus
+
+	servingStatus *prom.GaugeVec
+	statusChanges *prom.CounterVec
+}
+
+// NewServer returns a Server wrapping hs, starting from the status of the
+// overall server, the empty service.
+func NewServer(hs *health.Server) *Server {
+	s := &Server{
+		Server:   hs,
+		statuses: map[string]healthpb.HealthCheckResponse_ServingStatus{},
+		servingStatus: prom.NewGaugeVec(prom.GaugeOpts{
+			Name: "grpc_health_serving_status",
+			Help: "Serving status of the service, 1 for the current status.",
+		}, []string{"service", "status"}),
+		statusChanges: prom.NewCounterVec(prom.CounterOpts{
+			Name: "grpc_health_serving_status_changes_total",
+			Help: "Total number of changes of the serving status of the service into each status.",
+		}, []string{"service", "status"}),
+	}
+	s.refresh("")
+	return s
+}
+
+// SetServingStatus sets the serving status of the service on the wrapped
+// server.
+func (s *Server) SetServingStatus(service string, servingStatus healthpb.HealthCheckResponse_ServingStatus) {
+	s.Server.SetServingStatus(service, servingStatus)
+	s.refresh(service)
+}
+
+// Shutdown sets the serving status of all services to NOT_SERVING on the
+// wrapped server, which ignores status changes until Resume.
+func (s *Server) Shutdown() {
+	s.Server.Shutdown()
+	s.refreshAll()
+}
+
+// Resume sets the serving status of all services to SERVING on the wrapped
+// server.
+func (s *Server) Resume() {
+	s.Server.Resume()
+	s.refreshAll()
+}
+
+// Describe implements prometheus.Collector.
+func (s *Server) Describe(ch chan<- *prom.Desc) {
+	s.servingStatus.Describe(ch)
+	s.statusChanges.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (s *Server) Collect(ch chan<- prom.Metric) {
+	s.refreshAll()
+	s.servingStatus.Collect(ch)
+	s.statusChanges.Collect(ch)
+}
+
+func (s *Server) refreshAll() {
+	s.mu.Lock()
+	services := make([]string, 0, len(s.statuses))
+	for service := range s.statuses {
+		services = append(services, service)
+	}
+	s.mu.Unlock()
+	for _, service := range services {
+		s.refresh(service)
+	}
+}
+
+// refresh records the status of the service as reported by the wrapped
+// server, which alone knows whether a status change took effect.
+func (s *Server) refresh(service string) {
+	// The status is read under the lock so that concurrent refreshes don't
+	// record stale statuses.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+	if resp, err := s.Server.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service}); err == nil {
+		status = resp.GetStatus()
+	}
+	previous, known := s.statuses[service]
+	if !known {
+		for _, st := range servingStatuses {
+			s.servingStatus.WithLabelValues(service, st.String())
+			s.statusChanges.WithLabelValues(service, st.String())
+		}
+	} else if previous == status {
+		return
+	}
+	s.statuses[service] = status
+	if known {
+		s.servingStatus.WithLabelValues(service, previous.String()).Set(0)
+		s.statusChanges.WithLabelValues(service, status.String()).Inc()
+	}
+	s.servingStatus.WithLabelValues(service, status.String()).Set(1)
+}
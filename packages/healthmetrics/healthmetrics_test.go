@@ -0,0 +1,58 @@
+package healthmetrics
+
+import (
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+var (
+	_ healthpb.HealthServer = NewServer(health.NewServer())
+	_ prom.Collector        = NewServer(health.NewServer())
+)
+
+func TestServer(t *testing.T) {
+	hs := NewServer(health.NewServer())
+	requireStatus(t, hs, "", healthpb.HealthCheckResponse_SERVING)
+
+	hs.SetServingStatus("svc", healthpb.HealthCheckResponse_SERVING)
+	hs.SetServingStatus("svc", healthpb.HealthCheckResponse_NOT_SERVING)
+	hs.SetServingStatus("svc", healthpb.HealthCheckResponse_SERVING)
+	requireStatus(t, hs, "svc", healthpb.HealthCheckResponse_SERVING)
+	require.Equal(t, 1.0, testutil.ToFloat64(hs.statusChanges.WithLabelValues("svc", "NOT_SERVING")))
+	require.Equal(t, 1.0, testutil.ToFloat64(hs.statusChanges.WithLabelValues("svc", "SERVING")),
+		"the initial status must not be counted as a change")
+
+	hs.Shutdown()
+	hs.SetServingStatus("svc", healthpb.HealthCheckResponse_SERVING)
+	requireStatus(t, hs, "", healthpb.HealthCheckResponse_NOT_SERVING)
+	requireStatus(t, hs, "svc", healthpb.HealthCheckResponse_NOT_SERVING)
+	hs.Resume()
+	requireStatus(t, hs, "svc", healthpb.HealthCheckResponse_SERVING)
+	require.Equal(t, 2.0, testutil.ToFloat64(hs.statusChanges.WithLabelValues("svc", "NOT_SERVING")),
+		"statuses ignored after Shutdown must not be counted")
+
+	hs.Server.SetServingStatus("svc", healthpb.HealthCheckResponse_NOT_SERVING)
+	reg := prom.NewPedanticRegistry()
+	reg.MustRegister(hs)
+	_, err := reg.Gather()
+	require.NoError(t, err)
+	requireStatus(t, hs, "svc", healthpb.HealthCheckResponse_NOT_SERVING)
+	require.Equal(t, 3.0, testutil.ToFloat64(hs.statusChanges.WithLabelValues("svc", "NOT_SERVING")),
+		"statuses set on the wrapped server must be counted when scraped")
+}
+
+// requireStatus requires the status gauge of service to be 1 for status only.
+func requireStatus(t *testing.T, hs *Server, service string, status healthpb.HealthCheckResponse_ServingStatus) {
+	for _, st := range servingStatuses {
+		want := 0.0
+		if st == status {
+			want = 1
+		}
+		require.Equal(t, want, testutil.ToFloat64(hs.servingStatus.WithLabelValues(service, st.String())), "%q %v", service, st)
+	}
+}
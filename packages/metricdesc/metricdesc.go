@@ -0,0 +1,134 @@
+// Package metricdesc reads the names and variable labels of the metrics
+// described by Prometheus collectors, such as ServerMetrics and
+// ClientMetrics. It lets tooling generating queries, rules or dashboards use
+// the exact metric names and labels the collectors produce, including
+// configured namespaces and extension labels.
+package metricdesc
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Family describes a metric family of a collector.
+type Family struct {
+	// Name is the fully-qualified name of the metric.
+	Name string
+	// Labels are the variable labels of the metric.
+	Labels []string
+}
+
+// HasLabel reports whether the family has the given variable label.
+func (f Family) HasLabel(label string) bool {
+	for _, l := range f.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// familyLister is implemented by ServerMetrics and ClientMetrics, which
+// list their families without relying on Describe.
+type familyLister interface {
+	MetricFamilies() []grpc_prometheus.MetricFamily
+}
+
+var (
+	fqNameRegexp = regexp.MustCompile(`fqName: "([^"]*)"`)
+	// variableLabelsRegexp matches both "[a b]", printed by the client
+	// library in use, and "{a,b}", printed by later versions.
+	variableLabelsRegexp = regexp.MustCompile(`variableLabels: (?:\[([^\]]*)\]|\{([^}]*)\})`)
+)
+
+// Describe returns the families of the collector, in the order they are
+// first described. Families described more than once, like histograms with
+// per-method buckets, are returned once.
+//
+// The families of ServerMetrics and ClientMetrics are read from their
+// MetricFamilies method. Those of other collectors are read from the string
+// representation of their descriptors, as the client library doesn't
+// expose the fields of prometheus.Desc. Describe returns an error if a
+// descriptor can't be read, rather than families without labels.
+func Describe(c prometheus.Collector) ([]Family, error) {
+	if l, ok := c.(familyLister); ok {
+		var families []Family
+		for _, f := range l.MetricFamilies() {
+			families = append(families, Family{Name: f.Name, Labels: f.Labels})
+		}
+		return families, nil
+	}
+
+	ch := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(ch)
+		close(ch)
+	}()
+
+	var families []Family
+	var err error
+	seen := map[string]bool{}
+	for desc := range ch {
+		if err != nil {
+			continue
+		}
+		var family Family
+		family, err = parseDesc(desc.String())
+		if err != nil || seen[family.Name] {
+			continue
+		}
+		seen[family.Name] = true
+		families = append(families, family)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return families, nil
+}
+
+func parseDesc(s string) (Family, error) {
+	name := fqNameRegexp.FindStringSubmatch(s)
+	labels := variableLabelsRegexp.FindStringSubmatch(s)
+	if name == nil || labels == nil {
+		return Family{}, fmt.Errorf("metricdesc: can't read the name and labels of descriptor %s", s)
+	}
+	family := Family{Name: name[1]}
+	if labels[1] != "" {
+		family.Labels = strings.Fields(labels[1])
+	} else if labels[2] != "" {
+		family.Labels = strings.Split(labels[2], ",")
+	}
+	return family, nil
+}
+
+// Find returns the family with the given base name, e.g.
+// "grpc_server_handled_total", regardless of any namespace or subsystem
+// prefixed to it.
+func Find(families []Family, name string) (Family, bool) {
+	for _, f := range families {
+		if f.Name == name || strings.HasSuffix(f.Name, "_"+name) {
+			return f, true
+		}
+	}
+	return Family{}, false
+}
+
+// CodeClassRegexp returns a regular expression matching the names of the
+// codes DefaultCodeClasses classifies as class, e.g. for a grpc_code label
+// matcher selecting server errors. Generators share it so that they agree on
+// the classification.
+func CodeClassRegexp(class string) string {
+	var names []string
+	for code, c := range grpc_prometheus.DefaultCodeClasses() {
+		if c == class {
+			names = append(names, code.String())
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, "|")
+}
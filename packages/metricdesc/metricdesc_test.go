@@ -0,0 +1,80 @@
+package metricdesc
+
+import (
+	"testing"
+
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribe(t *testing.T) {
+	m := grpc_prometheus.NewServerMetrics(grpc_prometheus.CounterOption(func(o *prometheus.CounterOpts) { o.Namespace = "myapp" }))
+	families, err := Describe(m)
+	require.NoError(t, err)
+
+	require.Equal(t, []Family{
+		{Name: "myapp_grpc_server_started_total", Labels: []string{"grpc_type", "grpc_service", "grpc_method"}},
+		{Name: "myapp_grpc_server_handled_total", Labels: []string{"grpc_type", "grpc_service", "grpc_method", "grpc_code"}},
+		{Name: "myapp_grpc_server_msg_received_total", Labels: []string{"grpc_type", "grpc_service", "grpc_method"}},
+		{Name: "myapp_grpc_server_msg_sent_total", Labels: []string{"grpc_type", "grpc_service", "grpc_method"}},
+	}, families)
+
+	handled, ok := Find(families, "grpc_server_handled_total")
+	require.True(t, ok)
+	require.Equal(t, "myapp_grpc_server_handled_total", handled.Name)
+	require.True(t, handled.HasLabel("grpc_code"))
+
+	_, ok = Find(families, "grpc_server_handling_seconds")
+	require.False(t, ok, "histogram must not be described before being enabled")
+}
+
+func TestDescribeDeduplicatesFamilies(t *testing.T) {
+	m := grpc_prometheus.NewServerMetricsWithOptions(
+		grpc_prometheus.WithHandlingTimeHistogram(),
+		grpc_prometheus.WithHistogramBucketOverrides(map[string][]float64{"mwitkow.testproto.TestService": {1}}),
+	)
+
+	families, err := Describe(m)
+	require.NoError(t, err)
+	var histograms int
+	for _, f := range families {
+		if f.Name == "grpc_server_handling_seconds" {
+			histograms++
+		}
+	}
+	require.Equal(t, 1, histograms, "histograms with bucket overrides must be described once")
+}
+
+func TestDescribeUncheckedMetrics(t *testing.T) {
+	m := grpc_prometheus.NewServerMetricsWithOptions(grpc_prometheus.WithUncheckedCollector())
+	families, err := Describe(m)
+	require.NoError(t, err)
+	_, ok := Find(families, "grpc_server_handled_total")
+	require.True(t, ok, "unchecked metrics must be described without their descriptors")
+}
+
+func TestDescribeCollector(t *testing.T) {
+	families, err := Describe(prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: "myapp", Name: "requests_total"}, []string{"code", "method"}))
+	require.NoError(t, err)
+	require.Equal(t, []Family{{Name: "myapp_requests_total", Labels: []string{"code", "method"}}}, families)
+
+	families, err = Describe(prometheus.NewGauge(prometheus.GaugeOpts{Name: "up"}))
+	require.NoError(t, err)
+	require.Equal(t, []Family{{Name: "up"}}, families)
+}
+
+func TestParseDesc(t *testing.T) {
+	family, err := parseDesc(`Desc{fqName: "grpc_server_handled_total", help: "", constLabels: {}, variableLabels: {grpc_type,grpc_code}}`)
+	require.NoError(t, err)
+	require.Equal(t, Family{Name: "grpc_server_handled_total", Labels: []string{"grpc_type", "grpc_code"}}, family,
+		"the format of later client library versions must be read")
+
+	_, err = parseDesc(`Desc{fqName: "grpc_server_handled_total", labels: (grpc_type, grpc_code)}`)
+	require.Error(t, err, "descriptors in unknown formats must fail rather than lose their labels")
+}
+
+func TestCodeClassRegexp(t *testing.T) {
+	require.Equal(t, "DataLoss|DeadlineExceeded|Internal|Unavailable|Unimplemented|Unknown", CodeClassRegexp(grpc_prometheus.CodeClassServerError))
+	require.Equal(t, "OK", CodeClassRegexp(grpc_prometheus.CodeClassOK))
+}
@@ -0,0 +1,47 @@
+package metricsservice
+
+import (
+	"context"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/grpc"
+)
+
+// Client pulls the metrics of a process through its MetricsService.
+type Client struct {
+	client MetricsServiceClient
+}
+
+// NewClient returns a Client calling the MetricsService on cc.
+func NewClient(cc *grpc.ClientConn) *Client {
+	return &Client{client: NewMetricsServiceClient(cc)}
+}
+
+// Gather returns the metric families of the process.
+func (c *Client) Gather(ctx context.Context, opts ...grpc.CallOption) ([]*dto.MetricFamily, error) {
+	resp, err := c.client.Gather(ctx, &GatherRequest{}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return resp.GetMetricFamilies(), nil
+}
+
+// GatherText returns the metrics of the process in the OpenMetrics text
+// format, and their content type.
+func (c *Client) GatherText(ctx context.Context, opts ...grpc.CallOption) (text []byte, contentType string, err error) {
+	resp, err := c.client.GatherText(ctx, &GatherRequest{}, opts...)
+	if err != nil {
+		return nil, "", err
+	}
+	return resp.GetText(), resp.GetContentType(), nil
+}
+
+// Gatherer returns a prometheus.Gatherer gathering the metrics of the
+// process with ctx, e.g. for re-exposing them in the registry of the
+// scraping agent with prometheus.Gatherers.
+func (c *Client) Gatherer(ctx context.Context, opts ...grpc.CallOption) prom.Gatherer {
+	return prom.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return c.Gather(ctx, opts...)
+	})
+}
@@ -0,0 +1,282 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: metricsservice.proto
+
+package metricsservice // import "github.com/grpc-ecosystem/go-grpc-prometheus/packages/metricsservice"
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+import _go "github.com/prometheus/client_model/go"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type GatherRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GatherRequest) Reset()         { *m = GatherRequest{} }
+func (m *GatherRequest) String() string { return proto.CompactTextString(m) }
+func (*GatherRequest) ProtoMessage()    {}
+func (*GatherRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_metricsservice_551fba550706af74, []int{0}
+}
+func (m *GatherRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GatherRequest.Unmarshal(m, b)
+}
+func (m *GatherRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GatherRequest.Marshal(b, m, deterministic)
+}
+func (dst *GatherRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GatherRequest.Merge(dst, src)
+}
+func (m *GatherRequest) XXX_Size() int {
+	return xxx_messageInfo_GatherRequest.Size(m)
+}
+func (m *GatherRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GatherRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GatherRequest proto.InternalMessageInfo
+
+type GatherTextResponse struct {
+	// The content type of text, including the version of the format.
+	ContentType          string   `protobuf:"bytes,1,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	Text                 []byte   `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GatherTextResponse) Reset()         { *m = GatherTextResponse{} }
+func (m *GatherTextResponse) String() string { return proto.CompactTextString(m) }
+func (*GatherTextResponse) ProtoMessage()    {}
+func (*GatherTextResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_metricsservice_551fba550706af74, []int{1}
+}
+func (m *GatherTextResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GatherTextResponse.Unmarshal(m, b)
+}
+func (m *GatherTextResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GatherTextResponse.Marshal(b, m, deterministic)
+}
+func (dst *GatherTextResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GatherTextResponse.Merge(dst, src)
+}
+func (m *GatherTextResponse) XXX_Size() int {
+	return xxx_messageInfo_GatherTextResponse.Size(m)
+}
+func (m *GatherTextResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GatherTextResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GatherTextResponse proto.InternalMessageInfo
+
+func (m *GatherTextResponse) GetContentType() string {
+	if m != nil {
+		return m.ContentType
+	}
+	return ""
+}
+
+func (m *GatherTextResponse) GetText() []byte {
+	if m != nil {
+		return m.Text
+	}
+	return nil
+}
+
+type GatherResponse struct {
+	MetricFamilies       []*_go.MetricFamily `protobuf:"bytes,1,rep,name=metric_families,json=metricFamilies,proto3" json:"metric_families,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}            `json:"-"`
+	XXX_unrecognized     []byte              `json:"-"`
+	XXX_sizecache        int32               `json:"-"`
+}
+
+func (m *GatherResponse) Reset()         { *m = GatherResponse{} }
+func (m *GatherResponse) String() string { return proto.CompactTextString(m) }
+func (*GatherResponse) ProtoMessage()    {}
+func (*GatherResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_metricsservice_551fba550706af74, []int{2}
+}
+func (m *GatherResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GatherResponse.Unmarshal(m, b)
+}
+func (m *GatherResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GatherResponse.Marshal(b, m, deterministic)
+}
+func (dst *GatherResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GatherResponse.Merge(dst, src)
+}
+func (m *GatherResponse) XXX_Size() int {
+	return xxx_messageInfo_GatherResponse.Size(m)
+}
+func (m *GatherResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GatherResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GatherResponse proto.InternalMessageInfo
+
+func (m *GatherResponse) GetMetricFamilies() []*_go.MetricFamily {
+	if m != nil {
+		return m.MetricFamilies
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*GatherRequest)(nil), "grpc_prometheus.metricsservice.GatherRequest")
+	proto.RegisterType((*GatherTextResponse)(nil), "grpc_prometheus.metricsservice.GatherTextResponse")
+	proto.RegisterType((*GatherResponse)(nil), "grpc_prometheus.metricsservice.GatherResponse")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// MetricsServiceClient is the client API for MetricsService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type MetricsServiceClient interface {
+	// GatherText returns the metrics in the OpenMetrics text format.
+	GatherText(ctx context.Context, in *GatherRequest, opts ...grpc.CallOption) (*GatherTextResponse, error)
+	// Gather returns the metric families.
+	Gather(ctx context.Context, in *GatherRequest, opts ...grpc.CallOption) (*GatherResponse, error)
+}
+
+type metricsServiceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewMetricsServiceClient(cc *grpc.ClientConn) MetricsServiceClient {
+	return &metricsServiceClient{cc}
+}
+
+func (c *metricsServiceClient) GatherText(ctx context.Context, in *GatherRequest, opts ...grpc.CallOption) (*GatherTextResponse, error) {
+	out := new(GatherTextResponse)
+	err := c.cc.Invoke(ctx, "/grpc_prometheus.metricsservice.MetricsService/GatherText", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *metricsServiceClient) Gather(ctx context.Context, in *GatherRequest, opts ...grpc.CallOption) (*GatherResponse, error) {
+	out := new(GatherResponse)
+	err := c.cc.Invoke(ctx, "/grpc_prometheus.metricsservice.MetricsService/Gather", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MetricsServiceServer is the server API for MetricsService service.
+type MetricsServiceServer interface {
+	// GatherText returns the metrics in the OpenMetrics text format.
+	GatherText(context.Context, *GatherRequest) (*GatherTextResponse, error)
+	// Gather returns the metric families.
+	Gather(context.Context, *GatherRequest) (*GatherResponse, error)
+}
+
+func RegisterMetricsServiceServer(s *grpc.Server, srv MetricsServiceServer) {
+	s.RegisterService(&_MetricsService_serviceDesc, srv)
+}
+
+func _MetricsService_GatherText_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GatherRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MetricsServiceServer).GatherText(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpc_prometheus.metricsservice.MetricsService/GatherText",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MetricsServiceServer).GatherText(ctx, req.(*GatherRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MetricsService_Gather_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GatherRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MetricsServiceServer).Gather(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/grpc_prometheus.metricsservice.MetricsService/Gather",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MetricsServiceServer).Gather(ctx, req.(*GatherRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _MetricsService_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "grpc_prometheus.metricsservice.MetricsService",
+	HandlerType: (*MetricsServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GatherText",
+			Handler:    _MetricsService_GatherText_Handler,
+		},
+		{
+			MethodName: "Gather",
+			Handler:    _MetricsService_Gather_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "metricsservice.proto",
+}
+
+func init() {
+	proto.RegisterFile("metricsservice.proto", fileDescriptor_metricsservice_551fba550706af74)
+}
+
+var fileDescriptor_metricsservice_551fba550706af74 = []byte{
+	// 297 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x92, 0x4f, 0x4b, 0xc3, 0x40,
+	0x10, 0xc5, 0x8d, 0x4a, 0xc1, 0xed, 0x3f, 0x58, 0x3c, 0x94, 0x1e, 0xa4, 0xe6, 0xd4, 0x4b, 0x37,
+	0x50, 0x8f, 0xde, 0x3c, 0xe8, 0xa1, 0x78, 0x49, 0xeb, 0x45, 0x90, 0x92, 0x2e, 0x63, 0xb2, 0xd8,
+	0xcd, 0x6e, 0x77, 0x26, 0xd2, 0x7c, 0x69, 0x3f, 0x83, 0x34, 0x9b, 0x92, 0xe6, 0x22, 0xf5, 0x96,
+	0xf7, 0xf8, 0xe5, 0xcd, 0x9b, 0x61, 0xd9, 0xad, 0x06, 0x72, 0x4a, 0x22, 0x82, 0xfb, 0x56, 0x12,
+	0x84, 0x75, 0x86, 0x0c, 0xbf, 0x4b, 0x9d, 0x95, 0x6b, 0xeb, 0x8c, 0x06, 0xca, 0xa0, 0x40, 0xd1,
+	0xa6, 0xc6, 0xfd, 0x5a, 0x7b, 0x3c, 0x1c, 0xb2, 0xfe, 0x4b, 0x42, 0x19, 0xb8, 0x18, 0x76, 0x05,
+	0x20, 0x85, 0x0b, 0xc6, 0xbd, 0xb1, 0x82, 0x3d, 0xc5, 0x80, 0xd6, 0xe4, 0x08, 0xfc, 0x9e, 0xf5,
+	0xa4, 0xc9, 0x09, 0x72, 0x5a, 0x53, 0x69, 0x61, 0x14, 0x4c, 0x82, 0xe9, 0x4d, 0xdc, 0xad, 0xbd,
+	0x55, 0x69, 0x81, 0x73, 0x76, 0x4d, 0xb0, 0xa7, 0xd1, 0xe5, 0x24, 0x98, 0xf6, 0xe2, 0xea, 0x3b,
+	0xfc, 0x60, 0x83, 0x63, 0x7a, 0x1d, 0xb4, 0x60, 0x43, 0x5f, 0x60, 0xfd, 0x99, 0x68, 0xb5, 0x55,
+	0x80, 0xa3, 0x60, 0x72, 0x35, 0xed, 0xce, 0x43, 0xa1, 0x8c, 0x38, 0xa9, 0x2d, 0xb7, 0x0a, 0x72,
+	0x12, 0xaf, 0x15, 0xfc, 0x7c, 0x60, 0xcb, 0x78, 0xa0, 0x1b, 0xa5, 0x00, 0xe7, 0x3f, 0x01, 0x1b,
+	0x78, 0x00, 0x97, 0x7e, 0x3d, 0xbe, 0x63, 0xac, 0xa9, 0xcf, 0x67, 0xe2, 0xef, 0x6b, 0x88, 0xd6,
+	0xee, 0xe3, 0xf9, 0x79, 0xf8, 0xe9, 0x65, 0xc2, 0x0b, 0xae, 0x58, 0xc7, 0xfb, 0xff, 0x1d, 0x27,
+	0xce, 0xc5, 0x8f, 0xa3, 0x9e, 0xde, 0xde, 0x97, 0xa9, 0xa2, 0xac, 0xd8, 0x08, 0x69, 0x74, 0x74,
+	0xf8, 0x7b, 0x06, 0xd2, 0x60, 0x89, 0x04, 0x3a, 0x4a, 0xcd, 0xac, 0x72, 0x9a, 0xbc, 0xc8, 0x26,
+	0xf2, 0x2b, 0x49, 0x01, 0xa3, 0x76, 0xf0, 0x63, 0x5b, 0x6e, 0x3a, 0xd5, 0x5b, 0x78, 0xf8, 0x1d,
+	0x00, 0x40, 0xfc, 0x4e, 0xe2, 0x52, 0x02, 0x00, 0x00,
+}
@@ -0,0 +1,89 @@
+package metricsservice
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/grpc-ecosystem/go-grpc-prometheus/packages/testutil"
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestGatherThroughGRPC(t *testing.T) {
+	reg := prom.NewRegistry()
+	serverMetrics := grpc_prometheus.NewServerMetrics()
+	serverMetrics.MustRegister(reg)
+	clientMetrics := grpc_prometheus.NewClientMetrics()
+	clientMetrics.MustRegister(reg)
+	requests := prom.NewCounter(prom.CounterOpts{Name: "requests_total", Help: "Total number of requests."})
+	requests.Add(3)
+	reg.MustRegister(requests)
+
+	h := testutil.NewHarness(t,
+		[]grpc.ServerOption{grpc.UnaryInterceptor(serverMetrics.UnaryServerInterceptor())},
+		[]grpc.DialOption{grpc.WithUnaryInterceptor(clientMetrics.UnaryClientInterceptor())},
+		func(s *grpc.Server) { Register(s, reg) })
+	client := NewClient(h.Conn)
+
+	mfs, err := client.Gather(context.Background())
+	require.NoError(t, err)
+	names := map[string]*dto.MetricFamily{}
+	for _, mf := range mfs {
+		names[mf.GetName()] = mf
+	}
+	require.Contains(t, names, "requests_total")
+	require.Equal(t, 3.0, names["requests_total"].GetMetric()[0].GetCounter().GetValue())
+
+	text, contentType, err := client.GatherText(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, OpenMetricsContentType, contentType)
+	require.Contains(t, string(text), "# TYPE requests counter\n# HELP requests Total number of requests.\nrequests_total 3.0\n")
+	require.True(t, bytes.HasSuffix(text, []byte("# EOF\n")), "text must be terminated by EOF")
+
+	started, err := testutil.StartedCount(serverMetrics, "grpc_prometheus.metricsservice.MetricsService", "Gather")
+	require.NoError(t, err)
+	require.Zero(t, started, "the server interceptors must exclude the metrics service by default")
+	started, err = testutil.StartedCount(clientMetrics, "grpc_prometheus.metricsservice.MetricsService", "Gather")
+	require.NoError(t, err)
+	require.Zero(t, started, "the client interceptors must exclude the metrics service by default")
+}
+
+func TestWriteOpenMetrics(t *testing.T) {
+	mfs := []*dto.MetricFamily{
+		{
+			Name: proto.String("latency_seconds"),
+			Help: proto.String("Latency with \"quotes\"."),
+			Type: dto.MetricType_HISTOGRAM.Enum(),
+			Metric: []*dto.Metric{{
+				Label: []*dto.LabelPair{{Name: proto.String("path"), Value: proto.String("a\"b\n")}},
+				Histogram: &dto.Histogram{
+					SampleCount: proto.Uint64(3),
+					SampleSum:   proto.Float64(2.5),
+					Bucket:      []*dto.Bucket{{UpperBound: proto.Float64(1), CumulativeCount: proto.Uint64(2)}},
+				},
+			}},
+		},
+		{
+			Name:   proto.String("temperature"),
+			Type:   dto.MetricType_GAUGE.Enum(),
+			Metric: []*dto.Metric{{Gauge: &dto.Gauge{Value: proto.Float64(-1.5)}, TimestampMs: proto.Int64(1500)}},
+		},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, writeOpenMetrics(&buf, mfs))
+	require.Equal(t, `# TYPE latency_seconds histogram
+# HELP latency_seconds Latency with \"quotes\".
+latency_seconds_bucket{path="a\"b\n",le="1.0"} 2.0
+latency_seconds_bucket{path="a\"b\n",le="+Inf"} 3.0
+latency_seconds_sum{path="a\"b\n"} 2.5
+latency_seconds_count{path="a\"b\n"} 3.0
+# TYPE temperature gauge
+temperature -1.5 1.5
+# EOF
+`, buf.String())
+}
@@ -0,0 +1,127 @@
+package metricsservice
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// OpenMetricsContentType is the content type of the text returned by
+// GatherText.
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// writeOpenMetrics writes the metric families in the OpenMetrics text
+// format, terminated by "# EOF".
+func writeOpenMetrics(w io.Writer, mfs []*dto.MetricFamily) error {
+	bw := bufio.NewWriter(w)
+	for _, mf := range mfs {
+		writeFamily(bw, mf)
+	}
+	bw.WriteString("# EOF\n")
+	return bw.Flush()
+}
+
+func writeFamily(w *bufio.Writer, mf *dto.MetricFamily) {
+	name := mf.GetName()
+	typ := "unknown"
+	switch mf.GetType() {
+	case dto.MetricType_COUNTER:
+		// The family of a counter is named without the _total suffix of
+		// its samples.
+		name = strings.TrimSuffix(name, "_total")
+		typ = "counter"
+	case dto.MetricType_GAUGE:
+		typ = "gauge"
+	case dto.MetricType_SUMMARY:
+		typ = "summary"
+	case dto.MetricType_HISTOGRAM:
+		typ = "histogram"
+	}
+	w.WriteString("# TYPE " + name + " " + typ + "\n")
+	if mf.Help != nil {
+		w.WriteString("# HELP " + name + " " + helpEscaper.Replace(mf.GetHelp()) + "\n")
+	}
+	for _, m := range mf.GetMetric() {
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			writeSample(w, name+"_total", m, "", 0, m.GetCounter().GetValue())
+		case dto.MetricType_GAUGE:
+			writeSample(w, name, m, "", 0, m.GetGauge().GetValue())
+		case dto.MetricType_SUMMARY:
+			for _, q := range m.GetSummary().GetQuantile() {
+				writeSample(w, name, m, "quantile", q.GetQuantile(), q.GetValue())
+			}
+			writeSample(w, name+"_sum", m, "", 0, m.GetSummary().GetSampleSum())
+			writeSample(w, name+"_count", m, "", 0, float64(m.GetSummary().GetSampleCount()))
+		case dto.MetricType_HISTOGRAM:
+			infSeen := false
+			for _, b := range m.GetHistogram().GetBucket() {
+				infSeen = infSeen || math.IsInf(b.GetUpperBound(), +1)
+				writeSample(w, name+"_bucket", m, "le", b.GetUpperBound(), float64(b.GetCumulativeCount()))
+			}
+			if !infSeen {
+				writeSample(w, name+"_bucket", m, "le", math.Inf(+1), float64(m.GetHistogram().GetSampleCount()))
+			}
+			writeSample(w, name+"_sum", m, "", 0, m.GetHistogram().GetSampleSum())
+			writeSample(w, name+"_count", m, "", 0, float64(m.GetHistogram().GetSampleCount()))
+		default:
+			writeSample(w, name, m, "", 0, m.GetUntyped().GetValue())
+		}
+	}
+}
+
+// writeSample writes a sample with the labels of m, followed by the label
+// extraName with the value extraValue if extraName isn't empty.
+func writeSample(w *bufio.Writer, name string, m *dto.Metric, extraName string, extraValue, value float64) {
+	w.WriteString(name)
+	if len(m.GetLabel()) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, l := range m.GetLabel() {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(l.GetName() + `="` + labelEscaper.Replace(l.GetValue()) + `"`)
+		}
+		if extraName != "" {
+			if len(m.GetLabel()) > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(extraName + `="` + formatFloat(extraValue) + `"`)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	if m.TimestampMs != nil {
+		w.WriteByte(' ')
+		w.WriteString(strconv.FormatFloat(float64(m.GetTimestampMs())/1e3, 'f', -1, 64))
+	}
+	w.WriteByte('\n')
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = labelEscaper
+)
+
+// formatFloat formats v as OpenMetrics does, with integers written like
+// "1.0" so that bucket bounds and quantiles have a canonical form.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, +1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+	return s
+}
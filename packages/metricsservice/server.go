@@ -0,0 +1,58 @@
+// Package metricsservice exposes the metrics of a prometheus.Gatherer over
+// gRPC, for processes which only expose a gRPC port, e.g. sidecars. The
+// service is defined in metricsservice.proto. Scraping agents pull the
+// metrics through the existing gRPC listener with a Client:
+//
+//	metricsservice.Register(server, prometheus.DefaultGatherer)
+//
+// The interceptors of grpc_prometheus don't record the RPCs of the service
+// unless their excluded methods are overridden.
+package metricsservice
+
+import (
+	"bytes"
+	"context"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Server is a MetricsServiceServer serving the metrics of a gatherer.
+type Server struct {
+	gatherer prom.Gatherer
+}
+
+// NewServer returns a Server serving the metrics of gatherer.
+func NewServer(gatherer prom.Gatherer) *Server {
+	return &Server{gatherer: gatherer}
+}
+
+// Register registers a Server serving the metrics of gatherer on server.
+func Register(server *grpc.Server, gatherer prom.Gatherer) {
+	RegisterMetricsServiceServer(server, NewServer(gatherer))
+}
+
+// GatherText returns the metrics in the OpenMetrics text format.
+func (s *Server) GatherText(ctx context.Context, _ *GatherRequest) (*GatherTextResponse, error) {
+	resp, err := s.Gather(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := writeOpenMetrics(&buf, resp.MetricFamilies); err != nil {
+		return nil, status.Errorf(codes.Internal, "encoding metrics: %v", err)
+	}
+	return &GatherTextResponse{ContentType: OpenMetricsContentType, Text: buf.Bytes()}, nil
+}
+
+// Gather returns the metric families. Like promhttp, it fails if any
+// metric fails to be gathered.
+func (s *Server) Gather(context.Context, *GatherRequest) (*GatherResponse, error) {
+	mfs, err := s.gatherer.Gather()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "gathering metrics: %v", err)
+	}
+	return &GatherResponse{MetricFamilies: mfs}, nil
+}
@@ -0,0 +1,202 @@
+// Package pushmetrics exports the metrics of short-lived processes, like
+// batch jobs calling gRPC services, which exit before Prometheus scrapes
+// them. A Pusher pushes a collector, e.g. ClientMetrics, to a Pushgateway or
+// writes it to a file of the node exporter textfile collector, on an
+// interval and when closed:
+//
+//	p := pushmetrics.NewPushgateway("http://pushgateway:9091", "nightly_export", clientMetrics,
+//		pushmetrics.WithInstance("export-1"))
+//	defer p.Close()
+package pushmetrics
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// DefaultInterval is the default interval between pushes.
+const DefaultInterval = 15 * time.Second
+
+// ErrClosed is returned by Push once the Pusher is closed.
+var ErrClosed = errors.New("pushmetrics: pusher is closed")
+
+type options struct {
+	interval     time.Duration
+	grouping     map[string]string
+	client       *http.Client
+	errorHandler func(error)
+}
+
+// An Option configures a Pusher.
+type Option func(*options)
+
+// WithInterval sets the interval between pushes, DefaultInterval by default.
+// A zero interval only pushes on Push and Close.
+func WithInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.interval = interval
+	}
+}
+
+// WithInstance sets the instance label of the grouping key of a Pushgateway.
+func WithInstance(instance string) Option {
+	return WithGrouping("instance", instance)
+}
+
+// WithGrouping adds a label to the grouping key of a Pushgateway, next to the
+// job.
+func WithGrouping(name, value string) Option {
+	return func(o *options) {
+		o.grouping[name] = value
+	}
+}
+
+// WithHTTPClient sets the client pushing to a Pushgateway,
+// http.DefaultClient by default.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *options) {
+		o.client = client
+	}
+}
+
+// WithErrorHandler sets the function called with the errors of the pushes
+// made on the interval, which are dropped by default.
+func WithErrorHandler(handler func(error)) Option {
+	return func(o *options) {
+		o.errorHandler = handler
+	}
+}
+
+// registerer is implemented by ServerMetrics and ClientMetrics, which
+// register the metrics they enable later too, until unregistered.
+type registerer interface {
+	Register(prom.Registerer) error
+	Unregister(prom.Registerer) bool
+}
+
+// Pusher pushes the metrics of a collector on an interval and when closed.
+type Pusher struct {
+	opts options
+	push func() error
+	// unregister detaches the collector from the registry of the Pusher.
+	unregister func()
+
+	// mu serializes pushes, so that the push of Close is the last one.
+	mu sync.Mutex
+	// closed is set by Close, after which pushes fail with ErrClosed.
+	closed    bool
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewPushgateway returns a Pusher replacing the metrics of the grouping key,
+// the job and the labels of WithInstance and WithGrouping, on the
+// Pushgateway at url with the metrics of collector. It panics if collector
+// can't be registered with a new registry.
+func NewPushgateway(url, job string, collector prom.Collector, opts ...Option) *Pusher {
+	o := newOptions(opts)
+	reg, unregister := newRegistry(collector)
+	pusher := push.New(url, job).Gatherer(reg)
+	for name, value := range o.grouping {
+		pusher.Grouping(name, value)
+	}
+	if o.client != nil {
+		pusher.Client(o.client)
+	}
+	return start(o, pusher.Push, unregister)
+}
+
+// NewTextfile returns a Pusher atomically replacing filename with the
+// metrics of collector, for the textfile collector of the node exporter,
+// which reads files with a .prom suffix. It panics if collector can't be
+// registered with a new registry.
+func NewTextfile(filename string, collector prom.Collector, opts ...Option) *Pusher {
+	reg, unregister := newRegistry(collector)
+	return start(newOptions(opts), func() error {
+		return prom.WriteToTextfile(filename, reg)
+	}, unregister)
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		interval: DefaultInterval,
+		grouping: map[string]string{},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// newRegistry returns a registry with collector registered and a function
+// unregistering it.
+func newRegistry(collector prom.Collector) (*prom.Registry, func()) {
+	reg := prom.NewRegistry()
+	if r, ok := collector.(registerer); ok {
+		if err := r.Register(reg); err != nil {
+			panic(err)
+		}
+		return reg, func() { r.Unregister(reg) }
+	}
+	reg.MustRegister(collector)
+	return reg, func() { reg.Unregister(collector) }
+}
+
+func start(o options, push func() error, unregister func()) *Pusher {
+	p := &Pusher{opts: o, push: push, unregister: unregister, done: make(chan struct{})}
+	if o.interval > 0 {
+		p.wg.Add(1)
+		go p.pushLoop()
+	}
+	return p
+}
+
+func (p *Pusher) pushLoop() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.opts.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.Push(); err != nil && p.opts.errorHandler != nil {
+				p.opts.errorHandler(err)
+			}
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// Push pushes the metrics. It returns ErrClosed once the Pusher is closed, as
+// pushing the unregistered metrics would delete them on a Pushgateway.
+func (p *Pusher) Push() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return ErrClosed
+	}
+	return p.push()
+}
+
+// Close stops pushing on the interval, pushes the metrics a last time and
+// unregisters the collector from the registry of the Pusher. Calling Close
+// again returns the error of the first call.
+func (p *Pusher) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.done)
+		p.wg.Wait()
+		p.mu.Lock()
+		p.closeErr = p.push()
+		p.closed = true
+		p.mu.Unlock()
+		p.unregister()
+	})
+	return p.closeErr
+}
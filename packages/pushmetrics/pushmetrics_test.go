@@ -0,0 +1,141 @@
+package pushmetrics
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// newClientMetrics returns ClientMetrics with a recorded RPC.
+func newClientMetrics() *grpc_prometheus.ClientMetrics {
+	m := grpc_prometheus.NewClientMetrics()
+	m.UnaryClientInterceptor()(context.TODO(), "/mwitkow.testproto.TestService/Ping", nil, nil, nil,
+		func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+			return nil
+		})
+	return m
+}
+
+func TestPushgateway(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	pushed := make(chan struct{}, 100)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dec := expfmt.NewDecoder(r.Body, expfmt.ResponseFormat(r.Header))
+		var names []string
+		for {
+			mf := &dto.MetricFamily{}
+			if err := dec.Decode(mf); err != nil {
+				break
+			}
+			names = append(names, mf.GetName())
+		}
+		mu.Lock()
+		paths = append(paths, r.Method+" "+r.URL.Path+" "+strings.Join(names, ","))
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+		pushed <- struct{}{}
+	}))
+	defer gateway.Close()
+
+	p := NewPushgateway(gateway.URL, "nightly_export", newClientMetrics(),
+		WithInstance("export-1"), WithInterval(10*time.Millisecond))
+	select {
+	case <-pushed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("metrics must be pushed on the interval")
+	}
+	require.NoError(t, p.Close())
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.True(t, len(paths) >= 2, "metrics must be pushed on close, got %v", paths)
+	for _, path := range paths {
+		require.Equal(t, "PUT /metrics/job/nightly_export/instance/export-1 grpc_client_handled_total,grpc_client_msg_received_total,grpc_client_msg_sent_total,grpc_client_started_total", path)
+	}
+}
+
+func TestPushgatewayErrorHandler(t *testing.T) {
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer gateway.Close()
+
+	errs := make(chan error, 100)
+	p := NewPushgateway(gateway.URL, "nightly_export", newClientMetrics(),
+		WithInterval(10*time.Millisecond), WithErrorHandler(func(err error) { errs <- err }))
+	select {
+	case err := <-errs:
+		require.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("errors of pushes on the interval must be handled")
+	}
+	require.Error(t, p.Close(), "errors of the push on close must be returned")
+}
+
+func TestTextfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pushmetrics")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "grpc.prom")
+
+	p := NewTextfile(filename, newClientMetrics(), WithInterval(0))
+	require.NoError(t, p.Push())
+	require.NoError(t, p.Close())
+
+	b, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	require.Contains(t, string(b), `grpc_client_started_total{grpc_method="Ping",grpc_service="mwitkow.testproto.TestService",grpc_type="unary"} 1`)
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1, "temporary files must be renamed")
+
+	p = NewTextfile(filepath.Join(dir, "missing", "grpc.prom"), newClientMetrics(), WithInterval(0))
+	err = p.Close()
+	require.True(t, errors.Is(err, os.ErrNotExist), "got %v", err)
+}
+
+// unregisterRecorder records the registries the metrics are unregistered
+// from.
+type unregisterRecorder struct {
+	*grpc_prometheus.ClientMetrics
+	unregistered []prom.Registerer
+}
+
+func (r *unregisterRecorder) Unregister(reg prom.Registerer) bool {
+	r.unregistered = append(r.unregistered, reg)
+	return r.ClientMetrics.Unregister(reg)
+}
+
+func TestClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pushmetrics")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "grpc.prom")
+
+	m := &unregisterRecorder{ClientMetrics: newClientMetrics()}
+	p := NewTextfile(filename, m, WithInterval(0))
+	require.NoError(t, p.Close())
+	require.NoError(t, p.Close(), "closing twice must not panic")
+	require.Len(t, m.unregistered, 1, "the metrics must be unregistered on close")
+
+	require.Equal(t, ErrClosed, p.Push(), "pushing after close must not replace the pushed metrics")
+	b, err := ioutil.ReadFile(filename)
+	require.NoError(t, err)
+	require.Contains(t, string(b), "grpc_client_started_total")
+}
@@ -0,0 +1,112 @@
+// Package singleport serves gRPC and the /metrics endpoint of Prometheus on
+// a single port, for deployments exposing only one port. The handler routes
+// gRPC requests to grpc.Server.ServeHTTP, which requires HTTP/2; plaintext
+// listeners need h2c:
+//
+//	handler := singleport.NewHandler(grpcServer, prometheus.DefaultGatherer, singleport.WithHealthz(nil))
+//	http.ListenAndServe(":8080", h2c.NewHandler(handler, &http2.Server{}))
+//
+// grpc.Server.ServeHTTP uses the HTTP/2 server of net/http instead of the
+// transport of grpc-go, which is slower and lacks some of its features, see
+// its documentation.
+package singleport
+
+import (
+	"net/http"
+	"strings"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+)
+
+type options struct {
+	metricsPath string
+	handlerOpts promhttp.HandlerOpts
+	healthz     bool
+	healthCheck func() error
+}
+
+// An Option configures the handler returned by NewHandler.
+type Option func(*options)
+
+// WithMetricsPath sets the path of the metrics endpoint, "/metrics" by
+// default.
+func WithMetricsPath(path string) Option {
+	return func(o *options) {
+		o.metricsPath = path
+	}
+}
+
+// WithHandlerOpts sets the options of the promhttp handler serving the
+// metrics.
+func WithHandlerOpts(opts promhttp.HandlerOpts) Option {
+	return func(o *options) {
+		o.handlerOpts = opts
+	}
+}
+
+// WithHealthz serves /healthz, responding 200 OK, or 503 Service Unavailable
+// if check returns an error. A nil check always succeeds.
+func WithHealthz(check func() error) Option {
+	return func(o *options) {
+		o.healthz = true
+		o.healthCheck = check
+	}
+}
+
+// NewHandler returns a handler serving the gRPC requests, identified by
+// their application/grpc content type, with server, and GET requests of the
+// metrics endpoint with the metrics of gatherer. Other requests are answered
+// with 404 Not Found.
+func NewHandler(server *grpc.Server, gatherer prom.Gatherer, opts ...Option) http.Handler {
+	o := options{metricsPath: "/metrics"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(o.metricsPath, getOnly(promhttp.HandlerFor(gatherer, o.handlerOpts)))
+	if o.healthz {
+		mux.Handle("/healthz", getOnly(healthzHandler(o.healthCheck)))
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isGRPC(r) {
+			server.ServeHTTP(w, r)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// isGRPC reports whether r is a gRPC request. Its content type is
+// application/grpc, optionally followed by a codec, e.g. "+proto".
+func isGRPC(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	return r.ProtoMajor == 2 && (contentType == "application/grpc" ||
+		strings.HasPrefix(contentType, "application/grpc+") ||
+		strings.HasPrefix(contentType, "application/grpc;"))
+}
+
+func getOnly(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func healthzHandler(check func() error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if check != nil {
+			if err := check(); err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("ok\n"))
+	})
+}
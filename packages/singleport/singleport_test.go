@@ -0,0 +1,92 @@
+package singleport
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+)
+
+type testService struct {
+	pb_testproto.TestServiceServer
+}
+
+func (testService) Ping(_ context.Context, req *pb_testproto.PingRequest) (*pb_testproto.PingResponse, error) {
+	return &pb_testproto.PingResponse{Value: req.Value}, nil
+}
+
+func TestHandlerWithH2C(t *testing.T) {
+	reg := prom.NewRegistry()
+	metrics := grpc_prometheus.NewServerMetrics()
+	metrics.MustRegister(reg)
+	server := grpc.NewServer(grpc.UnaryInterceptor(metrics.UnaryServerInterceptor()))
+	pb_testproto.RegisterTestServiceServer(server, testService{})
+
+	var draining int32
+	handler := NewHandler(server, reg, WithHealthz(func() error {
+		if atomic.LoadInt32(&draining) == 1 {
+			return errors.New("draining")
+		}
+		return nil
+	}))
+	httpServer := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	defer httpServer.Close()
+
+	conn, err := grpc.Dial(strings.TrimPrefix(httpServer.URL, "http://"), grpc.WithInsecure())
+	require.NoError(t, err)
+	defer conn.Close()
+	resp, err := pb_testproto.NewTestServiceClient(conn).Ping(context.Background(), &pb_testproto.PingRequest{Value: "single port"})
+	require.NoError(t, err, "gRPC must be served over h2c")
+	require.Equal(t, "single port", resp.Value)
+
+	status, body := get(t, httpServer.URL+"/metrics")
+	require.Equal(t, http.StatusOK, status)
+	require.Contains(t, body, `grpc_server_handled_total{grpc_code="OK",grpc_method="Ping",grpc_service="mwitkow.testproto.TestService",grpc_type="unary"} 1`)
+
+	status, body = get(t, httpServer.URL+"/healthz")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "ok\n", body)
+	atomic.StoreInt32(&draining, 1)
+	status, _ = get(t, httpServer.URL+"/healthz")
+	require.Equal(t, http.StatusServiceUnavailable, status)
+
+	status, _ = get(t, httpServer.URL+"/other")
+	require.Equal(t, http.StatusNotFound, status)
+}
+
+func TestHandlerWithoutHealthz(t *testing.T) {
+	handler := NewHandler(grpc.NewServer(), prom.NewRegistry(), WithMetricsPath("/internal/metrics"))
+	for path, want := range map[string]int{
+		"/internal/metrics": http.StatusOK,
+		"/metrics":          http.StatusNotFound,
+		"/healthz":          http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, want, rec.Code, path)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/internal/metrics", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func get(t *testing.T, url string) (int, string) {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
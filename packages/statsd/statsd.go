@@ -0,0 +1,341 @@
+// Package statsd reports gRPC RPCs to a StatsD server over UDP, emitting the
+// events of the grpc_prometheus interceptors as StatsD counters and timers.
+// The grpc_* labels, and the custom labels of a ServerExtension, are sent as
+// DogStatsD tags:
+//
+//	sink, err := statsd.New("127.0.0.1:8125", statsd.WithSampleRate(0.1))
+//	if err != nil {
+//		return err
+//	}
+//	defer sink.Close()
+//	grpc.NewServer(
+//		grpc.UnaryInterceptor(grpc_prometheus.UnaryServerInterceptorWithReporter(sink.NewServerReporter)),
+//		grpc.StreamInterceptor(grpc_prometheus.StreamServerInterceptorWithReporter(sink.NewServerReporter)),
+//	)
+//
+// Metrics are named like their Prometheus counterparts, e.g. the handled
+// counter of servers is grpc.server.handled and the handling time is the
+// grpc.server.handling_time timer, in milliseconds.
+package statsd
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	// DefaultMaxPacketSize keeps packets within the MTU of Ethernet links.
+	DefaultMaxPacketSize = 1432
+	// DefaultFlushInterval is the longest time a metric is buffered.
+	DefaultFlushInterval = time.Second
+)
+
+type options struct {
+	prefix        string
+	sampleRate    float64
+	maxPacketSize int
+	flushInterval time.Duration
+	tags          []string
+	extension     grpc_prometheus.ServerExtension
+}
+
+// An Option configures a Sink.
+type Option func(*options)
+
+// WithPrefix sets the prefix of the metric names, "grpc" by default.
+func WithPrefix(prefix string) Option {
+	return func(o *options) {
+		o.prefix = prefix
+	}
+}
+
+// WithSampleRate sends only the given fraction of the events, between 0 and
+// 1, telling the StatsD server the rate to scale them by. All events are sent
+// by default.
+func WithSampleRate(rate float64) Option {
+	return func(o *options) {
+		o.sampleRate = rate
+	}
+}
+
+// WithMaxPacketSize sets the size up to which metrics are batched into a
+// single packet. A size of 0 sends every metric in its own packet.
+func WithMaxPacketSize(size int) Option {
+	return func(o *options) {
+		o.maxPacketSize = size
+	}
+}
+
+// WithFlushInterval sets the longest time a metric is buffered before it is
+// sent in a packet that isn't full.
+func WithFlushInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.flushInterval = interval
+	}
+}
+
+// WithTags adds constant tags, formatted as "key:value", to every metric.
+func WithTags(tags ...string) Option {
+	return func(o *options) {
+		o.tags = append(o.tags, tags...)
+	}
+}
+
+// WithExtension adds the custom labels of the extension, with the values it
+// returns for each RPC, as tags of the server metrics.
+func WithExtension(extension grpc_prometheus.ServerExtension) Option {
+	return func(o *options) {
+		o.extension = extension
+	}
+}
+
+// Sink sends metrics to a StatsD server. It is safe for concurrent use.
+type Sink struct {
+	opts options
+	conn net.Conn
+	// constTags is the formatted WithTags tags, ending with a separator if
+	// not empty.
+	constTags string
+
+	mu   sync.Mutex
+	buf  []byte
+	rand *rand.Rand
+	// closed is set by Close, after which events are dropped.
+	closed bool
+
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// New returns a Sink sending metrics to the StatsD server at addr, a UDP
+// host:port address.
+func New(addr string, opts ...Option) (*Sink, error) {
+	o := options{
+		prefix:        "grpc",
+		sampleRate:    1,
+		maxPacketSize: DefaultMaxPacketSize,
+		flushInterval: DefaultFlushInterval,
+		extension:     grpc_prometheus.DefaultExtension{},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Sink{
+		opts: o,
+		conn: conn,
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+		done: make(chan struct{}),
+	}
+	if len(o.tags) > 0 {
+		s.constTags = strings.Join(o.tags, ",") + ","
+	}
+	s.wg.Add(1)
+	go s.flushLoop()
+	return s, nil
+}
+
+func (s *Sink) flushLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.opts.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// Like other StatsD clients, metrics failing to send are dropped.
+			s.Flush()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Flush sends the buffered metrics.
+func (s *Sink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flushLocked()
+}
+
+func (s *Sink) flushLocked() error {
+	if len(s.buf) == 0 {
+		return nil
+	}
+	_, err := s.conn.Write(s.buf)
+	s.buf = s.buf[:0]
+	return err
+}
+
+// Close sends the buffered metrics and closes the connection. Events
+// reported afterwards are dropped. Calling Close again returns the error of
+// the first call.
+func (s *Sink) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.closed = true
+		s.closeErr = s.flushLocked()
+		if err := s.conn.Close(); s.closeErr == nil {
+			s.closeErr = err
+		}
+	})
+	return s.closeErr
+}
+
+// send buffers a metric of the given StatsD type, e.g. "c" or "ms", subject
+// to sampling. tags must not be empty.
+func (s *Sink) send(name, value, statsdType, tags string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	if s.opts.sampleRate < 1 && s.rand.Float64() >= s.opts.sampleRate {
+		return
+	}
+	line := make([]byte, 0, 64+len(tags))
+	line = append(line, s.opts.prefix...)
+	line = append(line, '.')
+	line = append(line, name...)
+	line = append(line, ':')
+	line = append(line, value...)
+	line = append(line, '|')
+	line = append(line, statsdType...)
+	if s.opts.sampleRate < 1 {
+		line = append(line, "|@"...)
+		line = strconv.AppendFloat(line, s.opts.sampleRate, 'g', -1, 64)
+	}
+	line = append(line, "|#"...)
+	line = append(line, s.constTags...)
+	line = append(line, tags...)
+
+	if len(s.buf) > 0 && len(s.buf)+1+len(line) > s.opts.maxPacketSize {
+		s.flushLocked()
+	}
+	if len(s.buf) > 0 {
+		s.buf = append(s.buf, '\n')
+	}
+	s.buf = append(s.buf, line...)
+	if len(s.buf) >= s.opts.maxPacketSize {
+		s.flushLocked()
+	}
+}
+
+func (s *Sink) count(name, tags string) {
+	s.send(name, "1", "c", tags)
+}
+
+func (s *Sink) timing(name string, elapsed time.Duration, tags string) {
+	s.send(name, strconv.FormatFloat(elapsed.Seconds()*1e3, 'f', -1, 64), "ms", tags)
+}
+
+// NewServerReporter is a grpc_prometheus.ServerReporterFactory sending the
+// metrics of server RPCs.
+func (s *Sink) NewServerReporter(_ context.Context, rpcType grpc_prometheus.RPCType, fullMethod string) grpc_prometheus.ServerReporter {
+	return &serverReporter{sink: s, tags: methodTags(rpcType, fullMethod)}
+}
+
+// NewClientReporter is a grpc_prometheus.ClientReporterFactory sending the
+// metrics of client RPCs.
+func (s *Sink) NewClientReporter(_ context.Context, rpcType grpc_prometheus.RPCType, fullMethod string) grpc_prometheus.ClientReporter {
+	return &clientReporter{sink: s, tags: methodTags(rpcType, fullMethod), streaming: rpcType != grpc_prometheus.Unary}
+}
+
+// methodTags returns the grpc_type, grpc_service and grpc_method tags.
+func methodTags(rpcType grpc_prometheus.RPCType, fullMethod string) string {
+	service, method := grpc_prometheus.SplitMethodName(fullMethod)
+	return "grpc_type:" + string(rpcType) + ",grpc_service:" + tagValue(service) + ",grpc_method:" + tagValue(method)
+}
+
+// withLabels appends the labels with the given values to tags.
+func withLabels(tags string, labels, values []string) string {
+	for i, label := range labels {
+		var value string
+		if i < len(values) {
+			value = values[i]
+		}
+		tags += "," + label + ":" + tagValue(value)
+	}
+	return tags
+}
+
+// tagValue replaces the characters separating tags and fields of the
+// DogStatsD format.
+var tagValue = strings.NewReplacer(",", "_", "|", "_", "\n", "_").Replace
+
+type serverReporter struct {
+	sink *Sink
+	tags string
+}
+
+func (r *serverReporter) Started(context.Context) {
+	r.sink.count("server.started", r.tags)
+}
+
+func (r *serverReporter) ReceivedMessage(ctx context.Context) {
+	ext := r.sink.opts.extension
+	r.sink.count("server.msg_received", withLabels(r.tags,
+		ext.ServerStreamMsgReceivedCounterCustomLabels(), ext.ServerStreamMsgReceivedCounterValues(ctx)))
+}
+
+func (r *serverReporter) SentMessage(ctx context.Context) {
+	ext := r.sink.opts.extension
+	r.sink.count("server.msg_sent", withLabels(r.tags,
+		ext.ServerStreamMsgSentCounterCustomLabels(), ext.ServerStreamMsgSentCounterValues(ctx)))
+}
+
+func (r *serverReporter) Handled(ctx context.Context, code codes.Code, elapsed time.Duration) {
+	ext := r.sink.opts.extension
+	r.sink.count("server.handled", withLabels(r.tags,
+		ext.ServerHandledCounterCustomLabels(), ext.ServerHandledCounterValues(ctx))+",grpc_code:"+code.String())
+	r.sink.timing("server.handling_time", elapsed, withLabels(r.tags,
+		ext.ServerHandledHistogramCustomLabels(), ext.ServerHandledHistogramValues(ctx)))
+}
+
+type clientReporter struct {
+	sink      *Sink
+	tags      string
+	streaming bool
+}
+
+func (r *clientReporter) Started() {
+	r.sink.count("client.started", r.tags)
+}
+
+func (r *clientReporter) ReceivedMessage(err error, elapsed time.Duration) {
+	if r.streaming {
+		r.sink.timing("client.msg_recv_time", elapsed, r.tags)
+	}
+	if err == nil {
+		r.sink.count("client.msg_received", r.tags)
+	}
+}
+
+func (r *clientReporter) SentMessage(err error, elapsed time.Duration) {
+	if r.streaming {
+		r.sink.timing("client.msg_send_time", elapsed, r.tags)
+	}
+	if err == nil {
+		r.sink.count("client.msg_sent", r.tags)
+	}
+}
+
+func (r *clientReporter) Handled(code codes.Code, elapsed time.Duration) {
+	r.sink.count("client.handled", r.tags+",grpc_code:"+code.String())
+	r.sink.timing("client.handling_time", elapsed, r.tags)
+}
@@ -0,0 +1,136 @@
+package statsd
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type tenantExtension struct {
+	grpc_prometheus.DefaultExtension
+}
+
+func (tenantExtension) ServerHandledCounterCustomLabels() []string {
+	return []string{"tenant"}
+}
+
+func (tenantExtension) ServerHandledCounterValues(context.Context) []string {
+	return []string{"acme,inc"}
+}
+
+// listen returns a local UDP listener and a function returning the packets
+// it received.
+func listen(t *testing.T) (string, func() []string) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn.LocalAddr().String(), func() []string {
+		var packets []string
+		buf := make([]byte, 65536)
+		for {
+			conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return packets
+			}
+			packets = append(packets, string(buf[:n]))
+		}
+	}
+}
+
+func TestServerReporter(t *testing.T) {
+	addr, received := listen(t)
+	sink, err := New(addr, WithExtension(tenantExtension{}), WithTags("env:test"), WithFlushInterval(time.Hour))
+	require.NoError(t, err)
+
+	grpc_prometheus.UnaryServerInterceptorWithReporter(sink.NewServerReporter)(context.TODO(), nil,
+		&grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingError"},
+		func(context.Context, interface{}) (interface{}, error) {
+			return nil, status.Error(codes.NotFound, "not found")
+		})
+	require.NoError(t, sink.Close())
+
+	packets := received()
+	require.Len(t, packets, 1, "metrics must be batched")
+	lines := strings.Split(packets[0], "\n")
+	tags := "env:test,grpc_type:unary,grpc_service:mwitkow.testproto.TestService,grpc_method:PingError"
+	require.Equal(t, "grpc.server.started:1|c|#"+tags, lines[0])
+	require.Equal(t, "grpc.server.msg_received:1|c|#"+tags, lines[1])
+	require.Equal(t, "grpc.server.handled:1|c|#"+tags+",tenant:acme_inc,grpc_code:NotFound", lines[2])
+	require.True(t, strings.HasPrefix(lines[3], "grpc.server.handling_time:"), lines[3])
+	require.True(t, strings.HasSuffix(lines[3], "|ms|#"+tags), lines[3])
+	require.Len(t, lines, 4, "failed RPCs must not report a sent message")
+}
+
+func TestClientReporter(t *testing.T) {
+	addr, received := listen(t)
+	sink, err := New(addr, WithPrefix("rpc"), WithMaxPacketSize(0))
+	require.NoError(t, err)
+
+	grpc_prometheus.UnaryClientInterceptorWithReporter(sink.NewClientReporter)(context.TODO(), "/mwitkow.testproto.TestService/Ping", nil, nil, nil,
+		func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+			return nil
+		})
+	require.NoError(t, sink.Close())
+
+	packets := received()
+	require.Len(t, packets, 5, "every metric must be sent in its own packet")
+	tags := "grpc_type:unary,grpc_service:mwitkow.testproto.TestService,grpc_method:Ping"
+	require.Equal(t, []string{
+		"rpc.client.started:1|c|#" + tags,
+		"rpc.client.msg_sent:1|c|#" + tags,
+		"rpc.client.msg_received:1|c|#" + tags,
+		"rpc.client.handled:1|c|#" + tags + ",grpc_code:OK",
+	}, packets[:4])
+	require.True(t, strings.HasPrefix(packets[4], "rpc.client.handling_time:"), packets[4])
+}
+
+func TestSampling(t *testing.T) {
+	addr, received := listen(t)
+	sink, err := New(addr, WithSampleRate(0.5), WithMaxPacketSize(200), WithFlushInterval(time.Hour))
+	require.NoError(t, err)
+	sink.rand = rand.New(rand.NewSource(1))
+
+	for i := 0; i < 100; i++ {
+		sink.count("server.started", "grpc_type:unary")
+	}
+	require.NoError(t, sink.Close())
+
+	var lines []string
+	for _, packet := range received() {
+		require.True(t, len(packet) <= 200, "packets must not exceed the max size")
+		lines = append(lines, strings.Split(packet, "\n")...)
+	}
+	require.InDelta(t, 50, len(lines), 15, "about half of the events must be sent")
+	require.Equal(t, "grpc.server.started:1|c|@0.5|#grpc_type:unary", lines[0])
+}
+
+func TestClose(t *testing.T) {
+	addr, received := listen(t)
+	sink, err := New(addr, WithFlushInterval(time.Hour))
+	require.NoError(t, err)
+
+	sink.count("server.started", "grpc_type:unary")
+	require.NoError(t, sink.Close())
+	require.NoError(t, sink.Close(), "closing twice must not panic")
+	sink.count("server.started", "grpc_type:unary")
+	require.NoError(t, sink.Flush())
+
+	require.Equal(t, []string{"grpc.server.started:1|c|#grpc_type:unary"}, received(), "events after Close must be dropped")
+}
+
+func TestMethodTags(t *testing.T) {
+	require.Equal(t, "grpc_type:unary,grpc_service:mwitkow.testproto.TestService,grpc_method:Ping",
+		methodTags(grpc_prometheus.Unary, "/mwitkow.testproto.TestService/Ping"))
+	require.Equal(t, "grpc_type:unary,grpc_service:unknown,grpc_method:unknown",
+		methodTags(grpc_prometheus.Unary, "Ping"), "tags must match the labels of malformed method names")
+}
@@ -0,0 +1,176 @@
+// Package testutil provides helpers for asserting the metrics recorded by
+// ServerMetrics and ClientMetrics in tests of instrumented services, without
+// scraping the text exposition format.
+package testutil
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// HandledCount returns the number of RPCs of the method completed with the
+// given code, summed over all RPC types and extension labels. metrics is
+// either a ServerMetrics or a ClientMetrics.
+func HandledCount(metrics prom.Collector, service, method string, code codes.Code) (float64, error) {
+	var count float64
+	err := visitSeries(metrics, "handled_total", service, method, func(m *dto.Metric) {
+		if labelValue(m, "grpc_code") == code.String() {
+			count += m.GetCounter().GetValue()
+		}
+	})
+	return count, err
+}
+
+// StartedCount returns the number of RPCs of the method started, summed over
+// all RPC types.
+func StartedCount(metrics prom.Collector, service, method string) (float64, error) {
+	var count float64
+	err := visitSeries(metrics, "started_total", service, method, func(m *dto.Metric) {
+		count += m.GetCounter().GetValue()
+	})
+	return count, err
+}
+
+// HistogramCount returns the number of RPCs of the method observed by the
+// handling time histogram, which must be enabled.
+func HistogramCount(metrics prom.Collector, service, method string) (uint64, error) {
+	var count uint64
+	err := visitSeries(metrics, "handling_seconds", service, method, func(m *dto.Metric) {
+		count += m.GetHistogram().GetSampleCount()
+	})
+	return count, err
+}
+
+// HistogramSum returns the total handling time in seconds of the RPCs of the
+// method observed by the handling time histogram, which must be enabled.
+func HistogramSum(metrics prom.Collector, service, method string) (float64, error) {
+	var sum float64
+	err := visitSeries(metrics, "handling_seconds", service, method, func(m *dto.Metric) {
+		sum += m.GetHistogram().GetSampleSum()
+	})
+	return sum, err
+}
+
+// AssertHandled asserts that exactly n RPCs of the method completed with the
+// given code.
+func AssertHandled(t testing.TB, metrics prom.Collector, service, method string, code codes.Code, n int) bool {
+	t.Helper()
+	count, err := HandledCount(metrics, service, method, code)
+	if err != nil {
+		t.Errorf("failed to read handled RPCs of /%s/%s: %v", service, method, err)
+		return false
+	}
+	if int(count) != n {
+		t.Errorf("expected %d RPCs of /%s/%s handled with %s; got %v", n, service, method, code, count)
+		return false
+	}
+	return true
+}
+
+// AssertStarted asserts that exactly n RPCs of the method were started.
+func AssertStarted(t testing.TB, metrics prom.Collector, service, method string, n int) bool {
+	t.Helper()
+	count, err := StartedCount(metrics, service, method)
+	if err != nil {
+		t.Errorf("failed to read started RPCs of /%s/%s: %v", service, method, err)
+		return false
+	}
+	if int(count) != n {
+		t.Errorf("expected %d RPCs of /%s/%s started; got %v", n, service, method, count)
+		return false
+	}
+	return true
+}
+
+// visitSeries calls visit for each series of the family with the given
+// suffix, e.g. "handled_total", that belongs to the method.
+func visitSeries(metrics prom.Collector, suffix, service, method string, visit func(*dto.Metric)) error {
+	reg := prom.NewRegistry()
+	if err := reg.Register(metrics); err != nil {
+		return err
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, family := range families {
+		name := family.GetName()
+		if !strings.HasSuffix(name, "grpc_server_"+suffix) && !strings.HasSuffix(name, "grpc_client_"+suffix) {
+			continue
+		}
+		found = true
+		for _, m := range family.GetMetric() {
+			if labelValue(m, "grpc_service") == service && labelValue(m, "grpc_method") == method {
+				visit(m)
+			}
+		}
+	}
+	if !found && suffix == "handling_seconds" {
+		return fmt.Errorf("no handling time histogram collected, is it enabled?")
+	}
+	return nil
+}
+
+func labelValue(m *dto.Metric, name string) string {
+	for _, lp := range m.GetLabel() {
+		if lp.GetName() == name {
+			return lp.GetValue()
+		}
+	}
+	return ""
+}
+
+// Harness runs a gRPC server on an in-memory bufconn listener, with a client
+// connection to it. It is stopped when the test finishes.
+type Harness struct {
+	Server *grpc.Server
+	Conn   *grpc.ClientConn
+
+	listener *bufconn.Listener
+}
+
+// NewHarness starts a gRPC server with the given options, typically the
+// interceptors of a ServerMetrics, after calling register to register the
+// services on it. The client connection is dialed with the given options,
+// typically the interceptors of a ClientMetrics.
+func NewHarness(t testing.TB, serverOpts []grpc.ServerOption, dialOpts []grpc.DialOption, register func(*grpc.Server)) *Harness {
+	t.Helper()
+	h := &Harness{
+		Server:   grpc.NewServer(serverOpts...),
+		listener: bufconn.Listen(1024 * 1024),
+	}
+	register(h.Server)
+	go h.Server.Serve(h.listener)
+
+	dialOpts = append([]grpc.DialOption{
+		grpc.WithInsecure(),
+		grpc.WithDialer(func(string, time.Duration) (net.Conn, error) {
+			return h.listener.Dial()
+		}),
+	}, dialOpts...)
+	conn, err := grpc.Dial("bufconn", dialOpts...)
+	if err != nil {
+		h.Server.Stop()
+		t.Fatalf("failed to dial bufconn server: %v", err)
+	}
+	h.Conn = conn
+	t.Cleanup(h.Close)
+	return h
+}
+
+// Close closes the client connection and stops the server.
+func (h *Harness) Close() {
+	h.Conn.Close()
+	h.Server.Stop()
+}
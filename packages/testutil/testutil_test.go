@@ -0,0 +1,76 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type testService struct {
+	pb_testproto.TestServiceServer
+}
+
+func (testService) PingEmpty(context.Context, *pb_testproto.Empty) (*pb_testproto.PingResponse, error) {
+	return &pb_testproto.PingResponse{}, nil
+}
+
+func (testService) PingError(_ context.Context, ping *pb_testproto.PingRequest) (*pb_testproto.Empty, error) {
+	return nil, status.Errorf(codes.Code(ping.ErrorCodeReturned), "Userspace error.")
+}
+
+func TestHarnessAndAssertions(t *testing.T) {
+	serverMetrics := grpc_prometheus.NewServerMetricsWithOptions(grpc_prometheus.WithHandlingTimeHistogram())
+	clientMetrics := grpc_prometheus.NewClientMetrics()
+
+	h := NewHarness(t,
+		[]grpc.ServerOption{grpc.UnaryInterceptor(serverMetrics.UnaryServerInterceptor())},
+		[]grpc.DialOption{grpc.WithUnaryInterceptor(clientMetrics.UnaryClientInterceptor())},
+		func(s *grpc.Server) { pb_testproto.RegisterTestServiceServer(s, testService{}) },
+	)
+	client := pb_testproto.NewTestServiceClient(h.Conn)
+
+	for i := 0; i < 2; i++ {
+		_, err := client.PingEmpty(context.TODO(), &pb_testproto.Empty{})
+		require.NoError(t, err)
+	}
+	_, err := client.PingError(context.TODO(), &pb_testproto.PingRequest{ErrorCodeReturned: uint32(codes.NotFound)})
+	require.Error(t, err)
+
+	const service = "mwitkow.testproto.TestService"
+	AssertStarted(t, serverMetrics, service, "PingEmpty", 2)
+	AssertHandled(t, serverMetrics, service, "PingEmpty", codes.OK, 2)
+	AssertHandled(t, serverMetrics, service, "PingError", codes.NotFound, 1)
+	AssertHandled(t, clientMetrics, service, "PingError", codes.NotFound, 1)
+	AssertHandled(t, clientMetrics, service, "PingError", codes.OK, 0)
+
+	count, err := HistogramCount(serverMetrics, service, "PingEmpty")
+	require.NoError(t, err)
+	require.EqualValues(t, 2, count)
+	sum, err := HistogramSum(serverMetrics, service, "PingEmpty")
+	require.NoError(t, err)
+	require.True(t, sum > 0)
+
+	_, err = HistogramCount(clientMetrics, service, "PingEmpty")
+	require.Error(t, err, "client histogram is not enabled")
+
+	recorder := &errorRecorder{TB: t}
+	require.False(t, AssertHandled(recorder, serverMetrics, service, "PingEmpty", codes.OK, 3))
+	require.Len(t, recorder.errors, 1)
+}
+
+// errorRecorder records errors instead of failing the test.
+type errorRecorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *errorRecorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
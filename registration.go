@@ -0,0 +1,75 @@
+package grpc_prometheus
+
+import (
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// registerAll registers all collectors with reg. If one of them fails to
+// register, those already registered are unregistered again.
+func registerAll(reg prom.Registerer, collectors []prom.Collector) error {
+	for i, c := range collectors {
+		if err := reg.Register(c); err != nil {
+			for _, registered := range collectors[:i] {
+				reg.Unregister(registered)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// unregisterAll unregisters all collectors from reg and reports whether all
+// of them were registered.
+func unregisterAll(reg prom.Registerer, collectors []prom.Collector) bool {
+	all := true
+	for _, c := range collectors {
+		if !reg.Unregister(c) {
+			all = false
+		}
+	}
+	return all
+}
+
+// registerChanged registers the collectors of updated missing from current
+// with all registerers, and unregisters those of current missing from
+// updated. There is no way to report errors to the caller enabling metrics,
+// they are ignored like prometheus.Register errors were by the package level
+// Enable* functions.
+func registerChanged(registerers []prom.Registerer, current, updated []prom.Collector) {
+	for _, c := range missingFrom(updated, current) {
+		for _, reg := range registerers {
+			reg.Register(c)
+		}
+	}
+	for _, c := range missingFrom(current, updated) {
+		for _, reg := range registerers {
+			reg.Unregister(c)
+		}
+	}
+}
+
+// missingFrom returns the collectors of collectors missing from others.
+func missingFrom(collectors, others []prom.Collector) []prom.Collector {
+	known := make(map[prom.Collector]bool, len(others))
+	for _, c := range others {
+		known[c] = true
+	}
+	var missing []prom.Collector
+	for _, c := range collectors {
+		if !known[c] {
+			missing = append(missing, c)
+		}
+	}
+	return missing
+}
+
+// removeRegisterer returns registerers without reg.
+func removeRegisterer(registerers []prom.Registerer, reg prom.Registerer) []prom.Registerer {
+	kept := registerers[:0]
+	for _, r := range registerers {
+		if r != reg {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
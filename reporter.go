@@ -0,0 +1,152 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// A ServerReporter receives the events of a single server RPC. The
+// interceptors report Started first, then ReceivedMessage and SentMessage
+// for every message successfully received and sent, and finally Handled
+// exactly once. Message events of streams may be reported concurrently by
+// the handler.
+type ServerReporter interface {
+	Started(ctx context.Context)
+	ReceivedMessage(ctx context.Context)
+	SentMessage(ctx context.Context)
+	// Handled reports the code the RPC completed with and the time elapsed
+	// since it started.
+	Handled(ctx context.Context, code codes.Code, elapsed time.Duration)
+}
+
+// A ServerReporterFactory returns the ServerReporter of a server RPC when it
+// starts. fullMethod is the full method name, e.g.
+// "/mwitkow.testproto.TestService/Ping".
+type ServerReporterFactory func(ctx context.Context, rpcType RPCType, fullMethod string) ServerReporter
+
+// A ClientReporter receives the events of a single client RPC. The
+// interceptors report Started first, then SentMessage and ReceivedMessage
+// after every attempt to send or receive a message, and finally Handled
+// exactly once, including for streams abandoned by the caller.
+type ClientReporter interface {
+	Started()
+	// ReceivedMessage reports an attempt to receive a message, which failed
+	// if err is not nil, and the time it took. Unary RPCs report their
+	// message once the call succeeded, with a zero duration.
+	ReceivedMessage(err error, elapsed time.Duration)
+	// SentMessage reports an attempt to send a message, which failed if err
+	// is not nil, and the time it took. Unary RPCs report their message
+	// before the call, with a zero duration.
+	SentMessage(err error, elapsed time.Duration)
+	// Handled reports the code the RPC completed with and the time elapsed
+	// since it started.
+	Handled(code codes.Code, elapsed time.Duration)
+}
+
+// A ClientReporterFactory returns the ClientReporter of a client RPC when it
+// starts.
+type ClientReporterFactory func(ctx context.Context, rpcType RPCType, fullMethod string) ClientReporter
+
+// noOpServerReporter is the ServerReporter of excluded methods.
+type noOpServerReporter struct{}
+
+func (noOpServerReporter) Started(context.Context)                            {}
+func (noOpServerReporter) ReceivedMessage(context.Context)                    {}
+func (noOpServerReporter) SentMessage(context.Context)                        {}
+func (noOpServerReporter) Handled(context.Context, codes.Code, time.Duration) {}
+
+// noOpClientReporter is the ClientReporter of excluded methods.
+type noOpClientReporter struct{}
+
+func (noOpClientReporter) Started()                             {}
+func (noOpClientReporter) ReceivedMessage(error, time.Duration) {}
+func (noOpClientReporter) SentMessage(error, time.Duration)     {}
+func (noOpClientReporter) Handled(codes.Code, time.Duration)    {}
+
+// UnaryServerInterceptorWithReporter is a gRPC server-side interceptor
+// reporting Unary RPCs to the ServerReporters created by factory. Codes of
+// errors are determined by the resolvers, falling back to
+// DefaultCodeResolver.
+func UnaryServerInterceptorWithReporter(factory ServerReporterFactory, resolvers ...CodeResolver) func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return reportUnaryServer(ctx, req, handler, factory(ctx, Unary, info.FullMethod), resolvers)
+	}
+}
+
+// StreamServerInterceptorWithReporter is a gRPC server-side interceptor
+// reporting Streaming RPCs to the ServerReporters created by factory.
+func StreamServerInterceptorWithReporter(factory ServerReporterFactory, resolvers ...CodeResolver) func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		reporter := factory(ss.Context(), streamRPCType(info), info.FullMethod)
+		return reportStreamServer(srv, ss, ss.Context(), handler, reporter, resolvers)
+	}
+}
+
+// UnaryClientInterceptorWithReporter is a gRPC client-side interceptor
+// reporting Unary RPCs to the ClientReporters created by factory.
+func UnaryClientInterceptorWithReporter(factory ClientReporterFactory, resolvers ...CodeResolver) func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return reportUnaryClient(ctx, method, req, reply, cc, invoker, opts, factory(ctx, Unary, method), resolvers)
+	}
+}
+
+// StreamClientInterceptorWithReporter is a gRPC client-side interceptor
+// reporting Streaming RPCs to the ClientReporters created by factory.
+func StreamClientInterceptorWithReporter(factory ClientReporterFactory, resolvers ...CodeResolver) func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		reporter := factory(ctx, clientStreamType(desc), method)
+		return reportStreamClient(ctx, desc, cc, method, streamer, opts, reporter, resolvers)
+	}
+}
+
+// reportUnaryServer calls the handler of a unary RPC, reporting its events.
+func reportUnaryServer(ctx context.Context, req interface{}, handler grpc.UnaryHandler, reporter ServerReporter, resolvers []CodeResolver) (interface{}, error) {
+	start := time.Now()
+	reporter.Started(ctx)
+	reporter.ReceivedMessage(ctx)
+	resp, err := handler(ctx, req)
+	reporter.Handled(ctx, resolveCode(resolvers, err), time.Since(start))
+	if err == nil {
+		reporter.SentMessage(ctx)
+	}
+	return resp, err
+}
+
+// reportStreamServer calls the handler of a streaming RPC with a stream
+// reporting the messages, ctx replacing the context of the stream.
+func reportStreamServer(srv interface{}, ss grpc.ServerStream, ctx context.Context, handler grpc.StreamHandler, reporter ServerReporter, resolvers []CodeResolver) error {
+	start := time.Now()
+	reporter.Started(ctx)
+	err := handler(srv, &monitoredServerStream{ss, ctx, reporter})
+	reporter.Handled(ctx, resolveCode(resolvers, err), time.Since(start))
+	return err
+}
+
+// reportUnaryClient invokes a unary RPC, reporting its events.
+func reportUnaryClient(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts []grpc.CallOption, reporter ClientReporter, resolvers []CodeResolver) error {
+	start := time.Now()
+	reporter.Started()
+	reporter.SentMessage(nil, 0)
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err == nil {
+		reporter.ReceivedMessage(nil, 0)
+	}
+	reporter.Handled(resolveCode(resolvers, err), time.Since(start))
+	return err
+}
+
+// reportStreamClient opens a streaming RPC, returning a stream reporting its
+// events.
+func reportStreamClient(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts []grpc.CallOption, reporter ClientReporter, resolvers []CodeResolver) (grpc.ClientStream, error) {
+	start := time.Now()
+	reporter.Started()
+	clientStream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		reporter.Handled(resolveCode(resolvers, err), time.Since(start))
+		return nil, err
+	}
+	return newMonitoredClientStream(ctx, clientStream, desc.ServerStreams, reporter, resolvers, start), nil
+}
@@ -0,0 +1,121 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recordingReporter is a ServerReporter and ClientReporter recording the
+// names of the events it receives.
+type recordingReporter struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recordingReporter) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recordingReporter) recorded() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+type recordingServerReporter struct{ *recordingReporter }
+
+func (r recordingServerReporter) Started(context.Context)         { r.record("started") }
+func (r recordingServerReporter) ReceivedMessage(context.Context) { r.record("received") }
+func (r recordingServerReporter) SentMessage(context.Context)     { r.record("sent") }
+func (r recordingServerReporter) Handled(_ context.Context, code codes.Code, _ time.Duration) {
+	r.record("handled " + code.String())
+}
+
+type recordingClientReporter struct{ *recordingReporter }
+
+func (r recordingClientReporter) Started() { r.record("started") }
+func (r recordingClientReporter) ReceivedMessage(err error, _ time.Duration) {
+	r.record(fmt.Sprintf("received %v", err))
+}
+func (r recordingClientReporter) SentMessage(err error, _ time.Duration) {
+	r.record(fmt.Sprintf("sent %v", err))
+}
+func (r recordingClientReporter) Handled(code codes.Code, _ time.Duration) {
+	r.record("handled " + code.String())
+}
+
+func TestServerInterceptorsWithReporter(t *testing.T) {
+	rec := &recordingReporter{}
+	var gotType RPCType
+	var gotMethod string
+	factory := func(_ context.Context, rpcType RPCType, fullMethod string) ServerReporter {
+		gotType, gotMethod = rpcType, fullMethod
+		return recordingServerReporter{rec}
+	}
+
+	UnaryServerInterceptorWithReporter(factory, ResolveErrorIs(errTestNotFound, codes.NotFound))(context.TODO(), nil,
+		&grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingError"},
+		func(context.Context, interface{}) (interface{}, error) { return nil, errTestNotFound })
+	require.Equal(t, Unary, gotType)
+	require.Equal(t, "/mwitkow.testproto.TestService/PingError", gotMethod)
+	require.Equal(t, []string{"started", "received", "handled NotFound"}, rec.recorded(), "failed unary RPCs must not report a sent message")
+
+	rec.events = nil
+	StreamServerInterceptorWithReporter(factory)(nil, &fakeServerStream{ctx: context.TODO()},
+		&grpc.StreamServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingStream", IsClientStream: true, IsServerStream: true},
+		func(_ interface{}, stream grpc.ServerStream) error {
+			stream.RecvMsg(nil)
+			stream.SendMsg(nil)
+			return nil
+		})
+	require.Equal(t, BidiStream, gotType)
+	require.Equal(t, []string{"started", "received", "sent", "handled OK"}, rec.recorded())
+}
+
+func TestClientInterceptorsWithReporter(t *testing.T) {
+	rec := &recordingReporter{}
+	var gotType RPCType
+	factory := func(_ context.Context, rpcType RPCType, _ string) ClientReporter {
+		gotType = rpcType
+		return recordingClientReporter{rec}
+	}
+
+	UnaryClientInterceptorWithReporter(factory)(context.TODO(), "/mwitkow.testproto.TestService/Ping", nil, nil, nil,
+		func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+			return nil
+		})
+	require.Equal(t, Unary, gotType)
+	require.Equal(t, []string{"started", "sent <nil>", "received <nil>", "handled OK"}, rec.recorded())
+
+	rec.events = nil
+	stream, err := StreamClientInterceptorWithReporter(factory)(context.TODO(), &grpc.StreamDesc{ServerStreams: true}, nil,
+		"/mwitkow.testproto.TestService/PingList",
+		func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+			return &fakeClientStream{msgs: 1}, nil
+		})
+	require.NoError(t, err)
+	stream.SendMsg(nil)
+	for stream.RecvMsg(nil) == nil {
+	}
+	require.Equal(t, ServerStream, gotType)
+	require.Equal(t, []string{"started", "sent <nil>", "received <nil>", "received EOF", "handled OK"}, rec.recorded())
+
+	rec.events = nil
+	_, err = StreamClientInterceptorWithReporter(factory)(context.TODO(), &grpc.StreamDesc{ServerStreams: true}, nil,
+		"/mwitkow.testproto.TestService/PingList",
+		func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+			return nil, status.Error(codes.Unavailable, "connection refused")
+		})
+	require.Error(t, err)
+	require.Equal(t, []string{"started", "handled Unavailable"}, rec.recorded(), "streams failing to open must be handled")
+}
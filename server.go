@@ -6,8 +6,11 @@
 package grpc_prometheus
 
 import (
+	"time"
+
 	prom "github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
 var (
@@ -24,10 +27,7 @@ var (
 )
 
 func init() {
-	prom.MustRegister(DefaultServerMetrics.serverStartedCounter)
-	prom.MustRegister(DefaultServerMetrics.serverHandledCounter)
-	prom.MustRegister(DefaultServerMetrics.serverStreamMsgReceivedCounter)
-	prom.MustRegister(DefaultServerMetrics.serverStreamMsgSentCounter)
+	DefaultServerMetrics.MustRegister(prom.DefaultRegisterer)
 }
 
 // Register takes a gRPC server and pre-initializes all counters to 0. This
@@ -44,5 +44,36 @@ func Register(server *grpc.Server) {
 // variable and the default Prometheus metrics registry.
 func EnableHandlingTimeHistogram(opts ...HistogramOption) {
 	DefaultServerMetrics.EnableHandlingTimeHistogram(opts...)
-	prom.Register(DefaultServerMetrics.serverHandledHistogram)
+}
+
+// EnablePanicHandling makes the server interceptors recover from panics in
+// handlers and record them as handled with the code of the error returned by
+// recoveryHandler. If recoveryHandler is nil the panic is re-raised after
+// being recorded with codes.Internal. This function acts on the
+// DefaultServerMetrics variable and the default Prometheus metrics registry.
+func EnablePanicHandling(recoveryHandler RecoveryHandlerFunc) {
+	DefaultServerMetrics.EnablePanicHandling(recoveryHandler)
+}
+
+// EnableSLOEvents turns on counting of RPCs against a latency objective in
+// grpc_server_slo_events_total. methodObjectives overrides defaultObjective
+// for the given full method names. This function acts on the
+// DefaultServerMetrics variable and the default Prometheus metrics registry.
+func EnableSLOEvents(defaultObjective time.Duration, methodObjectives map[string]time.Duration) {
+	DefaultServerMetrics.EnableSLOEvents(defaultObjective, methodObjectives)
+}
+
+// EnableHandledCodeClassCounter turns on counting of RPCs completed on the
+// server by the class of their code, with the given overrides of
+// DefaultCodeClasses applied. This function acts on the DefaultServerMetrics
+// variable and the default Prometheus metrics registry.
+func EnableHandledCodeClassCounter(overrides map[codes.Code]string) {
+	DefaultServerMetrics.EnableHandledCodeClassCounter(overrides)
+}
+
+// AddCodeResolvers adds resolvers used to determine the code reported for
+// errors returned by handlers. This function acts on the DefaultServerMetrics
+// variable.
+func AddCodeResolvers(resolvers ...CodeResolver) {
+	DefaultServerMetrics.AddCodeResolvers(resolvers...)
 }
@@ -0,0 +1,139 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/stats"
+)
+
+// ServerConnMetrics represents a collection of transport connection metrics
+// to be registered on a Prometheus metrics registry for a gRPC server. It
+// implements stats.Handler and must be installed on the server using the
+// grpc.StatsHandler server option.
+type ServerConnMetrics struct {
+	serverConnsOpen             prom.Gauge
+	serverConnsAcceptedCounter  prom.Counter
+	serverConnsClosedCounter    prom.Counter
+	serverConnDurationHistogram prom.Histogram
+	serverConnRPCsHistogram     prom.Histogram
+}
+
+// NewServerConnMetrics returns a ServerConnMetrics object. The counter
+// options are applied to all of its metrics, including the gauge and
+// histograms.
+func NewServerConnMetrics(counterOpts ...CounterOption) *ServerConnMetrics {
+	opts := counterOptions(counterOpts)
+	return &ServerConnMetrics{
+		serverConnsOpen: prom.NewGauge(
+			prom.GaugeOpts(opts.apply(prom.CounterOpts{
+				Name: "grpc_server_connections_open",
+				Help: "Number of transport connections currently open on the server.",
+			}))),
+		serverConnsAcceptedCounter: prom.NewCounter(
+			opts.apply(prom.CounterOpts{
+				Name: "grpc_server_connections_accepted_total",
+				Help: "Total number of transport connections accepted by the server.",
+			})),
+		serverConnsClosedCounter: prom.NewCounter(
+			opts.apply(prom.CounterOpts{
+				Name: "grpc_server_connections_closed_total",
+				Help: "Total number of transport connections closed on the server.",
+			})),
+		serverConnDurationHistogram: prom.NewHistogram(
+			histogramOptsFromCounterOpts(opts.apply(prom.CounterOpts{
+				Name: "grpc_server_connection_duration_seconds",
+				Help: "Histogram of the lifetime (seconds) of transport connections closed on the server.",
+			}), prom.ExponentialBuckets(1, 4, 10))),
+		serverConnRPCsHistogram: prom.NewHistogram(
+			histogramOptsFromCounterOpts(opts.apply(prom.CounterOpts{
+				Name: "grpc_server_connection_rpcs",
+				Help: "Histogram of the number of RPCs started on transport connections closed on the server.",
+			}), prom.ExponentialBuckets(1, 4, 10))),
+	}
+}
+
+func histogramOptsFromCounterOpts(o prom.CounterOpts, buckets []float64) prom.HistogramOpts {
+	return prom.HistogramOpts{
+		Namespace:   o.Namespace,
+		Subsystem:   o.Subsystem,
+		Name:        o.Name,
+		Help:        o.Help,
+		ConstLabels: o.ConstLabels,
+		Buckets:     buckets,
+	}
+}
+
+// Describe sends the super-set of all possible descriptors of metrics
+// collected by this Collector to the provided channel and returns once
+// the last descriptor has been sent.
+func (m *ServerConnMetrics) Describe(ch chan<- *prom.Desc) {
+	m.serverConnsOpen.Describe(ch)
+	m.serverConnsAcceptedCounter.Describe(ch)
+	m.serverConnsClosedCounter.Describe(ch)
+	m.serverConnDurationHistogram.Describe(ch)
+	m.serverConnRPCsHistogram.Describe(ch)
+}
+
+// Collect is called by the Prometheus registry when collecting
+// metrics. The implementation sends each collected metric via the
+// provided channel and returns once the last metric has been sent.
+func (m *ServerConnMetrics) Collect(ch chan<- prom.Metric) {
+	m.serverConnsOpen.Collect(ch)
+	m.serverConnsAcceptedCounter.Collect(ch)
+	m.serverConnsClosedCounter.Collect(ch)
+	m.serverConnDurationHistogram.Collect(ch)
+	m.serverConnRPCsHistogram.Collect(ch)
+}
+
+type connStateKey struct{}
+
+// connState is attached to the connection context in TagConn. On the server
+// side the contexts of all RPCs on the connection are derived from it.
+type connState struct {
+	rpcs      uint64 // accessed atomically, kept first for 64-bit alignment
+	startTime time.Time
+}
+
+// TagConn attaches the state tracking the lifetime and number of RPCs of the
+// connection to the given context.
+func (m *ServerConnMetrics) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return context.WithValue(ctx, connStateKey{}, &connState{startTime: time.Now()})
+}
+
+// HandleConn records the opening and closing of server connections.
+func (m *ServerConnMetrics) HandleConn(ctx context.Context, s stats.ConnStats) {
+	if s.IsClient() {
+		return
+	}
+	switch s.(type) {
+	case *stats.ConnBegin:
+		m.serverConnsAcceptedCounter.Inc()
+		m.serverConnsOpen.Inc()
+	case *stats.ConnEnd:
+		m.serverConnsClosedCounter.Inc()
+		m.serverConnsOpen.Dec()
+		if cs, ok := ctx.Value(connStateKey{}).(*connState); ok {
+			m.serverConnDurationHistogram.Observe(time.Since(cs.startTime).Seconds())
+			m.serverConnRPCsHistogram.Observe(float64(atomic.LoadUint64(&cs.rpcs)))
+		}
+	}
+}
+
+// TagRPC is a no-op, RPCs are counted against the connection state found in
+// the context passed to HandleRPC.
+func (m *ServerConnMetrics) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+// HandleRPC counts the RPCs started on each server connection.
+func (m *ServerConnMetrics) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if _, ok := s.(*stats.Begin); !ok || s.IsClient() {
+		return
+	}
+	if cs, ok := ctx.Value(connStateKey{}).(*connState); ok {
+		atomic.AddUint64(&cs.rpcs, 1)
+	}
+}
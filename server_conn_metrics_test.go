@@ -0,0 +1,61 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/stats"
+)
+
+var (
+	// server connection metrics must satisfy the Collector and stats.Handler interfaces
+	_ prometheus.Collector = NewServerConnMetrics()
+	_ stats.Handler        = NewServerConnMetrics()
+)
+
+func TestServerConnMetrics(t *testing.T) {
+	connMetrics := NewServerConnMetrics()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err, "must be able to allocate a port for serverListener")
+	server := grpc.NewServer(grpc.StatsHandler(connMetrics))
+	pb_testproto.RegisterTestServiceServer(server, &testService{t: t})
+	go server.Serve(lis)
+	defer server.Stop()
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 2*time.Second)
+	defer cancel()
+
+	clientConn, err := grpc.DialContext(ctx, lis.Addr().String(), grpc.WithInsecure(), grpc.WithBlock())
+	require.NoError(t, err, "must not error on client Dial")
+	client := pb_testproto.NewTestServiceClient(clientConn)
+	for i := 0; i < 3; i++ {
+		_, err = client.PingEmpty(ctx, &pb_testproto.Empty{})
+		require.NoError(t, err)
+	}
+
+	requireValueWithRetry(ctx, t, 1, connMetrics.serverConnsAcceptedCounter)
+	requireValueWithRetry(ctx, t, 1, connMetrics.serverConnsOpen)
+	requireValue(t, 0, connMetrics.serverConnsClosedCounter)
+
+	clientConn.Close()
+
+	requireValueWithRetry(ctx, t, 1, connMetrics.serverConnsClosedCounter)
+	requireValue(t, 0, connMetrics.serverConnsOpen)
+	requireValueHistCount(t, 1, connMetrics.serverConnDurationHistogram)
+	requireValueHistCount(t, 1, connMetrics.serverConnRPCsHistogram)
+	require.EqualValues(t, 3, histogramSum(t, connMetrics.serverConnRPCsHistogram), "all RPCs must be counted against the connection")
+}
+
+func histogramSum(t *testing.T, h prometheus.Histogram) float64 {
+	m := &dto.Metric{}
+	require.NoError(t, h.Write(m))
+	return m.GetHistogram().GetSampleSum()
+}
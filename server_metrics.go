@@ -2,10 +2,15 @@ package grpc_prometheus
 
 import (
 	"context"
-	"github.com/grpc-ecosystem/go-grpc-prometheus/packages/grpcstatus"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	prom "github.com/prometheus/client_golang/prometheus"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // ServerMetrics represents a collection of metrics to be registered on a
@@ -16,80 +21,444 @@ type ServerMetrics struct {
 	serverHandledCounter           *prom.CounterVec
 	serverStreamMsgReceivedCounter *prom.CounterVec
 	serverStreamMsgSentCounter     *prom.CounterVec
-	serverHandledHistogramEnabled  bool
-	serverHandledHistogramOpts     prom.HistogramOpts
-	serverHandledHistogram         *prom.HistogramVec
+	serverPanicsCounter            *prom.CounterVec
+	serverSLOEventsCounter         *prom.CounterVec
+	serverHandledCodeClassCounter  *prom.CounterVec
+	// serverMethodInfo is set when enabled by WithMethodInfo, with the
+	// method option labels following its fixed labels.
+	serverMethodInfo   *prom.GaugeVec
+	methodOptionLabels []MethodOptionLabel
+	// extensionLabels is set when the extension adds custom labels, whose
+	// values depend on the context of the RPC.
+	extensionLabels bool
+	// handlerLabels is set when handlers can set labels with SetLabel.
+	handlerLabels bool
+	// unchecked is set when Describe sends no descriptors.
+	unchecked bool
+	// families holds the families of the vectors.
+	families metricFamilies
+
+	// mu serializes updates of cfg, which holds a *serverConfig, and of
+	// registerers, the registries the metrics were registered with by
+	// Register.
+	mu          sync.Mutex
+	cfg         atomic.Value
+	registerers []prom.Registerer
+}
+
+// serverConfig is the configuration of ServerMetrics read by the
+// interceptors and the Collector methods. A published serverConfig is never
+// modified: updates are made on a copy, which then replaces it.
+type serverConfig struct {
+	handledHistogramEnabled bool
+	handledHistogramOpts    prom.HistogramOpts
+	handledHistogram        *methodHistogramVec
+	bucketOverrides         map[string][]float64
+	panicHandlingEnabled    bool
+	panicRecoveryHandler    RecoveryHandlerFunc
+	sloEventsEnabled        bool
+	sloDefaultObjective     time.Duration
+	sloMethodObjectives     map[string]time.Duration
+	codeClasses             map[codes.Code]string
+	handledCodeClassEnabled bool
+	codeResolvers           []CodeResolver
+	methodSettings          map[string]MethodSettings
+	// methods caches the children of the metrics used by each method. It
+	// is replaced on updates, as the children depend on the configuration.
+	methods *methodCache
 }
 
+// RecoveryHandlerFunc converts a panic recovered by the server interceptors
+// into the error returned to the client. A nil error fails the RPC with
+// codes.Internal.
+type RecoveryHandlerFunc func(p interface{}) error
+
 // NewServerMetrics returns a ServerMetrics object. Use a new instance of
 // ServerMetrics when not using the default Prometheus metrics registry, for
 // example when wanting to control which metrics are added to a registry as
 // opposed to automatically adding metrics via init functions.
 func NewServerMetrics(counterOpts ...CounterOption) *ServerMetrics {
-	return NewServerMetricsWithExtension(&DefaultExtension{}, counterOpts...)
+	return NewServerMetricsWithOptions(WithCounterOptions(counterOpts...))
+}
+
+// NewServerMetricsWithOptions returns a ServerMetrics object configured by
+// the given options, which fix the collected metrics before the
+// ServerMetrics is registered.
+func NewServerMetricsWithOptions(opts ...ServerMetricsOption) *ServerMetrics {
+	o := serverMetricsOptions{extension: &DefaultExtension{}}
+	for _, opt := range opts {
+		opt.applyToServerMetrics(&o)
+	}
+	extension := o.extension
+	if len(o.handlerLabels) > 0 {
+		extension = handlerLabelsExtension{ServerExtension: extension, keys: o.handlerLabels}
+	}
+	m := newServerMetrics(extension, o.counterOpts)
+	m.handlerLabels = len(o.handlerLabels) > 0
+	m.unchecked = o.unchecked
+	if o.methodInfo {
+		m.serverMethodInfo = newMethodInfoGauge(m.families, o.counterOpts, o.methodOptionLabels)
+		m.methodOptionLabels = o.methodOptionLabels
+	}
+	cfg := m.config()
+	cfg.bucketOverrides = o.bucketOverrides
+	for _, configure := range o.configure {
+		configure(m, cfg)
+	}
+	return m
 }
 
+// NewServerMetricsWithExtension returns a ServerMetrics object with custom
+// labels provided by extension. It is equivalent to calling
+// NewServerMetricsWithOptions with WithExtension and the counter options.
 func NewServerMetricsWithExtension(extension ServerExtension, counterOpts ...CounterOption) *ServerMetrics {
-	opts := counterOptions(counterOpts)
-	return &ServerMetrics{
+	return NewServerMetricsWithOptions(WithExtension(extension), WithCounterOptions(counterOpts...))
+}
+
+func newServerMetrics(extension ServerExtension, opts counterOptions) *ServerMetrics {
+	families := metricFamilies{}
+	m := &ServerMetrics{
 		extension: extension,
-		serverStartedCounter: prom.NewCounterVec(
+		families:  families,
+		serverStartedCounter: families.counterVec(
 			opts.apply(prom.CounterOpts{
 				Name: "grpc_server_started_total",
 				Help: "Total number of RPCs started on the server.",
 			}), []string{"grpc_type", "grpc_service", "grpc_method"}),
-		serverHandledCounter: prom.NewCounterVec(
+		serverHandledCounter: families.counterVec(
 			opts.apply(prom.CounterOpts{
 				Name: "grpc_server_handled_total",
 				Help: "Total number of RPCs completed on the server, regardless of success or failure.",
 			}), append(extension.ServerHandledCounterCustomLabels(), "grpc_type", "grpc_service", "grpc_method", "grpc_code")),
-		serverStreamMsgReceivedCounter: prom.NewCounterVec(
+		serverStreamMsgReceivedCounter: families.counterVec(
 			opts.apply(prom.CounterOpts{
 				Name: "grpc_server_msg_received_total",
 				Help: "Total number of RPC stream messages received on the server.",
 			}), append(extension.ServerStreamMsgReceivedCounterCustomLabels(), "grpc_type", "grpc_service", "grpc_method")),
-		serverStreamMsgSentCounter: prom.NewCounterVec(
+		serverStreamMsgSentCounter: families.counterVec(
 			opts.apply(prom.CounterOpts{
 				Name: "grpc_server_msg_sent_total",
 				Help: "Total number of gRPC stream messages sent by the server.",
 			}), append(extension.ServerStreamMsgSentCounterCustomLabels(), "grpc_type", "grpc_service", "grpc_method")),
-		serverHandledHistogramEnabled: false,
-		serverHandledHistogramOpts: prom.HistogramOpts{
+		serverPanicsCounter: families.counterVec(
+			opts.apply(prom.CounterOpts{
+				Name: "grpc_server_panics_total",
+				Help: "Total number of RPCs on the server whose handler panicked.",
+			}), []string{"grpc_type", "grpc_service", "grpc_method"}),
+		serverSLOEventsCounter: families.counterVec(
+			opts.apply(prom.CounterOpts{
+				Name: "grpc_server_slo_events_total",
+				Help: "Total number of RPCs completed on the server, by whether they met their latency objective without a server error.",
+			}), []string{"grpc_type", "grpc_service", "grpc_method", "result"}),
+		serverHandledCodeClassCounter: families.counterVec(
+			opts.apply(prom.CounterOpts{
+				Name: "grpc_server_handled_code_class_total",
+				Help: "Total number of RPCs completed on the server, by whether the code is a client or a server error.",
+			}), []string{"grpc_type", "grpc_service", "grpc_method", "grpc_code_class"}),
+	}
+	m.cfg.Store(&serverConfig{
+		handledHistogramOpts: prom.HistogramOpts{
 			Name:    "grpc_server_handling_seconds",
 			Help:    "Histogram of response latency (seconds) of gRPC that had been application-level handled by the server.",
 			Buckets: prom.DefBuckets,
 		},
-		serverHandledHistogram: nil,
+		codeClasses:    DefaultCodeClasses(),
+		methodSettings: defaultMethodSettings(),
+		methods:        newMethodCache(),
+	})
+	m.extensionLabels = len(extension.ServerHandledCounterCustomLabels()) > 0 ||
+		len(extension.ServerStreamMsgReceivedCounterCustomLabels()) > 0 ||
+		len(extension.ServerStreamMsgSentCounterCustomLabels()) > 0 ||
+		len(extension.ServerHandledHistogramCustomLabels()) > 0
+	return m
+}
+
+// config returns the current configuration, which must not be modified.
+func (m *ServerMetrics) config() *serverConfig {
+	return m.cfg.Load().(*serverConfig)
+}
+
+// resetMethodCache drops the cached children of the metrics. It must be
+// called whenever children are removed from the vectors, e.g. by Reset.
+func (m *ServerMetrics) resetMethodCache() {
+	m.updateConfig(func(*ServerMetrics, *serverConfig) {})
+}
+
+// updateConfig publishes a copy of the current configuration modified by
+// update.
+func (m *ServerMetrics) updateConfig(update func(m *ServerMetrics, c *serverConfig)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current := m.config()
+	c := *current
+	update(m, &c)
+	c.methods = newMethodCache()
+	m.cfg.Store(&c)
+	registerChanged(m.registerers, m.collectors(current), m.collectors(&c))
+}
+
+// collectors returns the vectors of the metrics collected with the given
+// configuration.
+func (m *ServerMetrics) collectors(c *serverConfig) []prom.Collector {
+	collectors := []prom.Collector{
+		m.serverStartedCounter,
+		m.serverHandledCounter,
+		m.serverStreamMsgReceivedCounter,
+		m.serverStreamMsgSentCounter,
+	}
+	if c.handledHistogramEnabled {
+		collectors = append(collectors, c.handledHistogram)
 	}
+	if c.panicHandlingEnabled {
+		collectors = append(collectors, m.serverPanicsCounter)
+	}
+	if c.sloEventsEnabled {
+		collectors = append(collectors, m.serverSLOEventsCounter)
+	}
+	if c.handledCodeClassEnabled {
+		collectors = append(collectors, m.serverHandledCodeClassCounter)
+	}
+	if m.serverMethodInfo != nil {
+		collectors = append(collectors, m.serverMethodInfo)
+	}
+	return collectors
+}
+
+// MetricFamilies returns the families of the enabled metrics. Unlike
+// Describe, it doesn't depend on the metrics being a checked collector.
+func (m *ServerMetrics) MetricFamilies() []MetricFamily {
+	return m.families.list(m.collectors(m.config()))
+}
+
+// Register registers the metrics with reg. Unlike registering the
+// ServerMetrics as a single Collector, metrics enabled after the call, like
+// histograms, are registered with reg as well.
+func (m *ServerMetrics) Register(reg prom.Registerer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := registerAll(reg, m.collectors(m.config())); err != nil {
+		return err
+	}
+	m.registerers = append(m.registerers, reg)
+	return nil
+}
+
+// MustRegister works like Register but panics where Register would have
+// returned an error.
+func (m *ServerMetrics) MustRegister(reg prom.Registerer) {
+	if err := m.Register(reg); err != nil {
+		panic(err)
+	}
+}
+
+// Unregister unregisters the metrics registered by Register from reg. It
+// reports whether all of them were registered.
+func (m *ServerMetrics) Unregister(reg prom.Registerer) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.registerers = removeRegisterer(m.registerers, reg)
+	return unregisterAll(reg, m.collectors(m.config()))
+}
+
+// Reset deletes all series of all metrics, including metrics that are not
+// enabled. It is meant to isolate tests using the same ServerMetrics, like
+// DefaultServerMetrics, from each other.
+func (m *ServerMetrics) Reset() {
+	cfg := m.config()
+	m.serverStartedCounter.Reset()
+	m.serverHandledCounter.Reset()
+	m.serverStreamMsgReceivedCounter.Reset()
+	m.serverStreamMsgSentCounter.Reset()
+	if cfg.handledHistogram != nil {
+		cfg.handledHistogram.Reset()
+	}
+	m.serverPanicsCounter.Reset()
+	m.serverSLOEventsCounter.Reset()
+	m.serverHandledCodeClassCounter.Reset()
+	if m.serverMethodInfo != nil {
+		m.serverMethodInfo.Reset()
+	}
+	m.resetMethodCache()
 }
 
 // EnableHandlingTimeHistogram enables histograms being registered when
 // registering the ServerMetrics on a Prometheus registry. Histograms can be
 // expensive on Prometheus servers. It takes options to configure histogram
 // options such as the defined buckets.
+//
+// Deprecated: use the WithHandlingTimeHistogram option of
+// NewServerMetricsWithOptions, which fixes the collected metrics before the
+// ServerMetrics is registered.
 func (m *ServerMetrics) EnableHandlingTimeHistogram(opts ...HistogramOption) {
+	m.updateConfig(func(m *ServerMetrics, c *serverConfig) { m.enableHandlingTimeHistogram(c, opts) })
+}
+
+func (m *ServerMetrics) enableHandlingTimeHistogram(c *serverConfig, opts []HistogramOption) {
 	for _, o := range opts {
-		o(&m.serverHandledHistogramOpts)
+		o(&c.handledHistogramOpts)
 	}
-	if !m.serverHandledHistogramEnabled {
-		m.serverHandledHistogram = prom.NewHistogramVec(
-			m.serverHandledHistogramOpts,
-			[]string{"grpc_type", "grpc_service", "grpc_method"},
+	if !c.handledHistogramEnabled {
+		c.handledHistogram = newMethodHistogramVec(
+			c.handledHistogramOpts,
+			append(m.extension.ServerHandledHistogramCustomLabels(), "grpc_type", "grpc_service", "grpc_method"),
+			c.bucketOverrides,
 		)
 	}
-	m.serverHandledHistogramEnabled = true
+	c.handledHistogramEnabled = true
+}
+
+// Settings returns the settings which can be changed at runtime.
+func (m *ServerMetrics) Settings() Settings {
+	cfg := m.config()
+	return Settings{
+		Histograms: map[string]bool{cfg.handledHistogramOpts.Name: cfg.handledHistogramEnabled},
+		Methods:    copyMethodSettings(cfg.methodSettings),
+	}
+}
+
+// SetHistogramEnabled enables or disables the handling time histogram,
+// named grpc_server_handling_seconds, while RPCs are in flight. It is
+// enabled with the histogram options last passed to WithHandlingTimeHistogram
+// or EnableHandlingTimeHistogram.
+func (m *ServerMetrics) SetHistogramEnabled(name string, enabled bool) error {
+	if name != m.config().handledHistogramOpts.Name {
+		return unknownHistogram(name)
+	}
+	m.updateConfig(func(m *ServerMetrics, c *serverConfig) {
+		if enabled {
+			m.enableHandlingTimeHistogram(c, nil)
+		} else {
+			c.handledHistogramEnabled, c.handledHistogram = false, nil
+		}
+	})
+	return nil
+}
+
+// SetMethodSettings sets the settings of a service or full method name while
+// RPCs are in flight.
+func (m *ServerMetrics) SetMethodSettings(method string, settings MethodSettings) {
+	m.updateConfig(func(_ *ServerMetrics, c *serverConfig) {
+		c.methodSettings = withMethodSettings(c.methodSettings, method, &settings)
+	})
+}
+
+// DeleteMethodSettings restores the default settings of a service or full
+// method name while RPCs are in flight.
+func (m *ServerMetrics) DeleteMethodSettings(method string) {
+	m.updateConfig(func(_ *ServerMetrics, c *serverConfig) {
+		c.methodSettings = withMethodSettings(c.methodSettings, method, nil)
+	})
+}
+
+// EnablePanicHandling makes the interceptors recover from panics in the
+// handler, so that the RPC is still recorded as handled. Recovered panics are
+// also counted in grpc_server_panics_total, which is registered together with
+// the ServerMetrics once enabled. If recoveryHandler is nil the panic is
+// re-raised after being recorded with codes.Internal, otherwise the RPC fails
+// and is recorded with the error returned by recoveryHandler.
+//
+// Deprecated: use the WithPanicHandling option of
+// NewServerMetricsWithOptions.
+func (m *ServerMetrics) EnablePanicHandling(recoveryHandler RecoveryHandlerFunc) {
+	m.updateConfig(func(_ *ServerMetrics, c *serverConfig) { c.enablePanicHandling(recoveryHandler) })
+}
+
+func (c *serverConfig) enablePanicHandling(recoveryHandler RecoveryHandlerFunc) {
+	c.panicRecoveryHandler = recoveryHandler
+	c.panicHandlingEnabled = true
+}
+
+// EnableSLOEvents enables counting of RPCs completed on the server against a
+// latency objective in grpc_server_slo_events_total, which is registered
+// together with the ServerMetrics once enabled. Each RPC is counted with the
+// result "error" if it failed with a server error, "slow" if it took longer
+// than its objective, and "good" otherwise. Unlike histograms these counters
+// are cheap enough to build burn-rate alerts on. methodObjectives overrides
+// defaultObjective for the given full method names, e.g.
+// "/mwitkow.testproto.TestService/Ping".
+//
+// Deprecated: use the WithSLOEvents option of
+// NewServerMetricsWithOptions.
+func (m *ServerMetrics) EnableSLOEvents(defaultObjective time.Duration, methodObjectives map[string]time.Duration) {
+	m.updateConfig(func(_ *ServerMetrics, c *serverConfig) { c.enableSLOEvents(defaultObjective, methodObjectives) })
+}
+
+func (c *serverConfig) enableSLOEvents(defaultObjective time.Duration, methodObjectives map[string]time.Duration) {
+	c.sloDefaultObjective = defaultObjective
+	c.sloMethodObjectives = make(map[string]time.Duration, len(methodObjectives))
+	for method, objective := range methodObjectives {
+		c.sloMethodObjectives[method] = objective
+	}
+	c.sloEventsEnabled = true
+}
+
+// EnableHandledCodeClassCounter enables counting of RPCs completed on the
+// server by the class of their code in grpc_server_handled_code_class_total,
+// which is registered together with the ServerMetrics once enabled. Codes are
+// classified by DefaultCodeClasses, with the given overrides applied. The
+// classification also decides which codes count as errors for the latency
+// objective events.
+//
+// Deprecated: use the WithHandledCodeClassCounter option of
+// NewServerMetricsWithOptions.
+func (m *ServerMetrics) EnableHandledCodeClassCounter(overrides map[codes.Code]string) {
+	m.updateConfig(func(_ *ServerMetrics, c *serverConfig) { c.enableHandledCodeClassCounter(overrides) })
+}
+
+func (c *serverConfig) enableHandledCodeClassCounter(overrides map[codes.Code]string) {
+	c.codeClasses = mergeCodeClasses(overrides)
+	c.handledCodeClassEnabled = true
+}
+
+// AddCodeResolvers adds resolvers used to determine the code reported for
+// errors returned by handlers. The resolvers are consulted in the order they
+// were added, before falling back to DefaultCodeResolver.
+//
+// Deprecated: use the WithCodeResolvers option of
+// NewServerMetricsWithOptions.
+func (m *ServerMetrics) AddCodeResolvers(resolvers ...CodeResolver) {
+	m.updateConfig(func(_ *ServerMetrics, c *serverConfig) { c.addCodeResolvers(resolvers) })
+}
+
+func (c *serverConfig) addCodeResolvers(resolvers []CodeResolver) {
+	// Never append in place, published configurations may share the slice.
+	c.codeResolvers = append(append([]CodeResolver(nil), c.codeResolvers...), resolvers...)
+}
+
+// latencyObjective returns the latency objective of the given method.
+func (c *serverConfig) latencyObjective(fullMethod string) time.Duration {
+	if objective, ok := c.sloMethodObjectives[fullMethod]; ok {
+		return objective
+	}
+	return c.sloDefaultObjective
 }
 
 // Describe sends the super-set of all possible descriptors of metrics
 // collected by this Collector to the provided channel and returns once
 // the last descriptor has been sent.
 func (m *ServerMetrics) Describe(ch chan<- *prom.Desc) {
+	if m.unchecked {
+		return
+	}
+	cfg := m.config()
 	m.serverStartedCounter.Describe(ch)
 	m.serverHandledCounter.Describe(ch)
 	m.serverStreamMsgReceivedCounter.Describe(ch)
 	m.serverStreamMsgSentCounter.Describe(ch)
-	if m.serverHandledHistogramEnabled {
-		m.serverHandledHistogram.Describe(ch)
+	if cfg.handledHistogramEnabled {
+		cfg.handledHistogram.Describe(ch)
+	}
+	if cfg.panicHandlingEnabled {
+		m.serverPanicsCounter.Describe(ch)
+	}
+	if cfg.sloEventsEnabled {
+		m.serverSLOEventsCounter.Describe(ch)
+	}
+	if cfg.handledCodeClassEnabled {
+		m.serverHandledCodeClassCounter.Describe(ch)
+	}
+	if m.serverMethodInfo != nil {
+		m.serverMethodInfo.Describe(ch)
 	}
 }
 
@@ -97,54 +466,115 @@ func (m *ServerMetrics) Describe(ch chan<- *prom.Desc) {
 // metrics. The implementation sends each collected metric via the
 // provided channel and returns once the last metric has been sent.
 func (m *ServerMetrics) Collect(ch chan<- prom.Metric) {
+	cfg := m.config()
 	m.serverStartedCounter.Collect(ch)
 	m.serverHandledCounter.Collect(ch)
 	m.serverStreamMsgReceivedCounter.Collect(ch)
 	m.serverStreamMsgSentCounter.Collect(ch)
-	if m.serverHandledHistogramEnabled {
-		m.serverHandledHistogram.Collect(ch)
+	if cfg.handledHistogramEnabled {
+		cfg.handledHistogram.Collect(ch)
+	}
+	if cfg.panicHandlingEnabled {
+		m.serverPanicsCounter.Collect(ch)
+	}
+	if cfg.sloEventsEnabled {
+		m.serverSLOEventsCounter.Collect(ch)
+	}
+	if cfg.handledCodeClassEnabled {
+		m.serverHandledCodeClassCounter.Collect(ch)
+	}
+	if m.serverMethodInfo != nil {
+		m.serverMethodInfo.Collect(ch)
 	}
 }
 
 // UnaryServerInterceptor is a gRPC server-side interceptor that provides Prometheus monitoring for Unary RPCs.
 func (m *ServerMetrics) UnaryServerInterceptor() func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
 		monitor := newServerReporter(m, Unary, info.FullMethod)
-		monitor.ReceivedMessage(ctx)
-		resp, err := handler(ctx, req)
-		st, _ := grpcstatus.FromError(err)
-		monitor.Handled(ctx, st.Code())
-		if err == nil {
-			monitor.SentMessage(ctx)
+		if monitor.settings.Excluded {
+			return handler(ctx, req)
 		}
-		return resp, err
+		if m.handlerLabels {
+			ctx = newLabelBagContext(ctx)
+		}
+		defer handlePanic(ctx, monitor, &err)
+		return reportUnaryServer(ctx, req, handler, monitor, monitor.cfg.codeResolvers)
 	}
 }
 
 // StreamServerInterceptor is a gRPC server-side interceptor that provides Prometheus monitoring for Streaming RPCs.
 func (m *ServerMetrics) StreamServerInterceptor() func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
-	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
 		monitor := newServerReporter(m, streamRPCType(info), info.FullMethod)
-		err := handler(srv, &monitoredServerStream{ss, monitor})
-		st, _ := grpcstatus.FromError(err)
-		monitor.Handled(ss.Context(), st.Code())
-		return err
+		if monitor.settings.Excluded {
+			return handler(srv, ss)
+		}
+		ctx := ss.Context()
+		if m.handlerLabels {
+			ctx = newLabelBagContext(ctx)
+		}
+		defer handlePanic(ctx, monitor, &err)
+		return reportStreamServer(srv, ss, ctx, handler, monitor, monitor.cfg.codeResolvers)
+	}
+}
+
+// NewServerReporter returns a ServerReporter recording an RPC in the
+// metrics. It is a ServerReporterFactory for combining the metrics with
+// other reporters. Panic handling, handler labels and code resolvers only
+// apply to the interceptors of ServerMetrics.
+func (m *ServerMetrics) NewServerReporter(_ context.Context, rpcType RPCType, fullMethod string) ServerReporter {
+	r := newServerReporter(m, rpcType, fullMethod)
+	if r.settings.Excluded {
+		return noOpServerReporter{}
 	}
+	return r
+}
+
+// handlePanic records a panic of the handler when panic handling is enabled.
+// It must be deferred directly by the interceptors for recover to take effect.
+func handlePanic(ctx context.Context, monitor *serverReporter, err *error) {
+	if !monitor.cfg.panicHandlingEnabled {
+		return
+	}
+	p := recover()
+	if p == nil {
+		return
+	}
+	if monitor.cfg.panicRecoveryHandler == nil {
+		monitor.Panicked(ctx, codes.Internal)
+		panic(p)
+	}
+	*err = monitor.cfg.panicRecoveryHandler(p)
+	if *err == nil {
+		*err = status.Error(codes.Internal, "handler panicked")
+	}
+	monitor.Panicked(ctx, resolveCode(monitor.cfg.codeResolvers, *err))
 }
 
 // InitializeMetrics initializes all metrics, with their appropriate null
 // value, for all gRPC methods registered on a gRPC server. This is useful, to
-// ensure that all metrics exist when collecting and querying.
+// ensure that all metrics exist when collecting and querying. Excluded
+// methods are skipped. It also sets grpc_server_method_info when enabled by
+// WithMethodInfo.
 func (m *ServerMetrics) InitializeMetrics(server *grpc.Server) {
+	methodSettings := m.config().methodSettings
 	serviceInfo := server.GetServiceInfo()
+	descriptors := methodDescriptors{}
 	for serviceName, info := range serviceInfo {
 		for _, mInfo := range info.Methods {
+			if resolveMethodSettings(methodSettings, serviceName, "/"+serviceName+"/"+mInfo.Name).Excluded {
+				continue
+			}
 			preRegisterMethod(m, serviceName, &mInfo)
+			if m.serverMethodInfo != nil {
+				m.setMethodInfo(serviceName, info.Metadata, &mInfo, descriptors)
+			}
 		}
 	}
 }
 
-func streamRPCType(info *grpc.StreamServerInfo) grpcType {
+func streamRPCType(info *grpc.StreamServerInfo) RPCType {
 	if info.IsClientStream && !info.IsServerStream {
 		return ClientStream
 	} else if !info.IsClientStream && info.IsServerStream {
@@ -156,13 +586,20 @@ func streamRPCType(info *grpc.StreamServerInfo) grpcType {
 // monitoredStream wraps grpc.ServerStream allowing each Sent/Recv of message to increment counters.
 type monitoredServerStream struct {
 	grpc.ServerStream
-	monitor *serverReporter
+	// ctx is the context of the stream, carrying the label bag of the RPC
+	// when handler labels are used.
+	ctx     context.Context
+	monitor ServerReporter
+}
+
+func (s *monitoredServerStream) Context() context.Context {
+	return s.ctx
 }
 
 func (s *monitoredServerStream) SendMsg(m interface{}) error {
 	err := s.ServerStream.SendMsg(m)
 	if err == nil {
-		s.monitor.SentMessage(s.ServerStream.Context())
+		s.monitor.SentMessage(s.ctx)
 	}
 	return err
 }
@@ -170,7 +607,7 @@ func (s *monitoredServerStream) SendMsg(m interface{}) error {
 func (s *monitoredServerStream) RecvMsg(m interface{}) error {
 	err := s.ServerStream.RecvMsg(m)
 	if err == nil {
-		s.monitor.ReceivedMessage(s.ServerStream.Context())
+		s.monitor.ReceivedMessage(s.ctx)
 	}
 	return err
 }
@@ -179,14 +616,28 @@ func (s *monitoredServerStream) RecvMsg(m interface{}) error {
 func preRegisterMethod(metrics *ServerMetrics, serviceName string, mInfo *grpc.MethodInfo) {
 	methodName := mInfo.Name
 	methodType := string(typeFromMethodInfo(mInfo))
+	cfg := metrics.config()
 	// These are just references (no increments), as just referencing will create the labels but not set values.
 	metrics.serverStartedCounter.GetMetricWithLabelValues(methodType, serviceName, methodName)
 	metrics.serverStreamMsgReceivedCounter.GetMetricWithLabelValues(methodType, serviceName, methodName)
 	metrics.serverStreamMsgSentCounter.GetMetricWithLabelValues(methodType, serviceName, methodName)
-	if metrics.serverHandledHistogramEnabled {
-		metrics.serverHandledHistogram.GetMetricWithLabelValues(methodType, serviceName, methodName)
+	if cfg.handledHistogramEnabled {
+		cfg.handledHistogram.forMethod(serviceName, "/"+serviceName+"/"+methodName).GetMetricWithLabelValues(methodType, serviceName, methodName)
+	}
+	if cfg.panicHandlingEnabled {
+		metrics.serverPanicsCounter.GetMetricWithLabelValues(methodType, serviceName, methodName)
+	}
+	if cfg.sloEventsEnabled {
+		for _, result := range allSLOResults {
+			metrics.serverSLOEventsCounter.GetMetricWithLabelValues(methodType, serviceName, methodName, result)
+		}
 	}
 	for _, code := range allCodes {
 		metrics.serverHandledCounter.GetMetricWithLabelValues(methodType, serviceName, methodName, code.String())
 	}
+	if cfg.handledCodeClassEnabled {
+		for _, class := range allCodeClasses {
+			metrics.serverHandledCodeClassCounter.GetMetricWithLabelValues(methodType, serviceName, methodName, class)
+		}
+	}
 }
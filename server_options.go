@@ -0,0 +1,143 @@
+package grpc_prometheus
+
+import (
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// A ServerMetricsOption configures ServerMetrics when calling
+// NewServerMetricsWithOptions. CounterOption values are ServerMetricsOptions
+// too and apply to all counters.
+type ServerMetricsOption interface {
+	applyToServerMetrics(*serverMetricsOptions)
+}
+
+type serverMetricsOptions struct {
+	extension       ServerExtension
+	counterOpts     counterOptions
+	bucketOverrides map[string][]float64
+	handlerLabels   []string
+	unchecked       bool
+	// methodInfo enables grpc_server_method_info, with methodOptionLabels.
+	methodInfo         bool
+	methodOptionLabels []MethodOptionLabel
+	configure          []func(m *ServerMetrics, c *serverConfig)
+}
+
+type serverMetricsOptionFunc func(*serverMetricsOptions)
+
+func (f serverMetricsOptionFunc) applyToServerMetrics(o *serverMetricsOptions) {
+	f(o)
+}
+
+func (f CounterOption) applyToServerMetrics(o *serverMetricsOptions) {
+	o.counterOpts = append(o.counterOpts, f)
+}
+
+// configureServer returns a ServerMetricsOption applying configure to the
+// initial configuration of the ServerMetrics.
+func configureServer(configure func(m *ServerMetrics, c *serverConfig)) ServerMetricsOption {
+	return serverMetricsOptionFunc(func(o *serverMetricsOptions) {
+		o.configure = append(o.configure, configure)
+	})
+}
+
+// WithExtension adds the custom labels provided by extension to the server
+// metrics.
+func WithExtension(extension ServerExtension) ServerMetricsOption {
+	return serverMetricsOptionFunc(func(o *serverMetricsOptions) {
+		o.extension = extension
+	})
+}
+
+// WithHandlerLabels declares labels whose values are set by handlers with
+// SetLabel during the RPC, e.g. whether a cache was hit. The labels are added
+// to the handled counter and the handling time histogram, after any labels of
+// the ServerExtension. RPCs whose handler didn't set a label have an empty
+// value for it.
+func WithHandlerLabels(keys ...string) ServerMetricsOption {
+	return serverMetricsOptionFunc(func(o *serverMetricsOptions) {
+		o.handlerLabels = append(o.handlerLabels, keys...)
+	})
+}
+
+// WithHandlingTimeHistogram enables the grpc_server_handling_seconds
+// histogram, configured by the given histogram options. Histograms can be
+// expensive on Prometheus servers.
+func WithHandlingTimeHistogram(opts ...HistogramOption) ServerMetricsOption {
+	return configureServer(func(m *ServerMetrics, c *serverConfig) {
+		m.enableHandlingTimeHistogram(c, opts)
+	})
+}
+
+// WithHistogramBucketOverrides overrides the buckets of the handling time
+// histogram for the given services, e.g. "mwitkow.testproto.TestService",
+// and full method names, e.g. "/mwitkow.testproto.TestService/Ping". Method
+// overrides take precedence over service overrides. Methods without an
+// override use the buckets of WithHandlingTimeHistogram.
+func WithHistogramBucketOverrides(overrides map[string][]float64) ServerMetricsOption {
+	return serverMetricsOptionFunc(func(o *serverMetricsOptions) {
+		o.bucketOverrides = copyBucketOverrides(o.bucketOverrides, overrides)
+	})
+}
+
+// WithPanicHandling makes the interceptors recover from panics in handlers,
+// counting them in grpc_server_panics_total. If recoveryHandler is nil the
+// panic is re-raised after being recorded as handled with codes.Internal,
+// otherwise the RPC fails and is recorded with the error it returns.
+func WithPanicHandling(recoveryHandler RecoveryHandlerFunc) ServerMetricsOption {
+	return configureServer(func(_ *ServerMetrics, c *serverConfig) {
+		c.enablePanicHandling(recoveryHandler)
+	})
+}
+
+// WithSLOEvents enables counting of RPCs against a latency objective in
+// grpc_server_slo_events_total. methodObjectives overrides defaultObjective
+// for the given full method names.
+func WithSLOEvents(defaultObjective time.Duration, methodObjectives map[string]time.Duration) ServerMetricsOption {
+	return configureServer(func(_ *ServerMetrics, c *serverConfig) {
+		c.enableSLOEvents(defaultObjective, methodObjectives)
+	})
+}
+
+// WithHandledCodeClassCounter enables counting of RPCs by the class of their
+// code in grpc_server_handled_code_class_total, with the given overrides of
+// DefaultCodeClasses applied.
+func WithHandledCodeClassCounter(overrides map[codes.Code]string) ServerMetricsOption {
+	return configureServer(func(_ *ServerMetrics, c *serverConfig) {
+		c.enableHandledCodeClassCounter(overrides)
+	})
+}
+
+// WithCodeResolvers adds resolvers used to determine the code reported for
+// errors returned by handlers, consulted in order before DefaultCodeResolver.
+func WithCodeResolvers(resolvers ...CodeResolver) ServerMetricsOption {
+	return configureServer(func(_ *ServerMetrics, c *serverConfig) {
+		c.addCodeResolvers(resolvers)
+	})
+}
+
+// WithExcludedMethods sets the services, e.g. "mwitkow.testproto.TestService",
+// and full method names, e.g. "/mwitkow.testproto.TestService/Ping", whose
+// RPCs the interceptors don't record. It replaces the default exclusion of
+// the service of packages/metricsservice; calling it without methods records
+// all RPCs.
+func WithExcludedMethods(methods ...string) ServerMetricsOption {
+	return configureServer(func(_ *ServerMetrics, c *serverConfig) {
+		c.methodSettings = withExcludedMethods(c.methodSettings, methods)
+	})
+}
+
+// WithUncheckedCollector makes the ServerMetrics an unchecked collector,
+// whose Describe sends no descriptors, so that pedantic registries accept
+// histograms enabled at runtime with SetHistogramEnabled when the
+// ServerMetrics is registered as a single collector. Other registries don't
+// check collected metrics against the descriptors, and it is not needed with
+// Register. Tooling reading the metrics of the ServerMetrics must use
+// MetricFamilies rather than Describe, as packages/metricdesc does.
+func WithUncheckedCollector() ServerMetricsOption {
+	return serverMetricsOptionFunc(func(o *serverMetricsOptions) {
+		o.unchecked = true
+	})
+}
@@ -11,50 +11,106 @@ import (
 )
 
 type serverReporter struct {
-	metrics     *ServerMetrics
-	rpcType     grpcType
-	serviceName string
-	methodName  string
-	startTime   time.Time
+	metrics    *ServerMetrics
+	cfg        *serverConfig
+	method     *serverMethodMetrics
+	settings   MethodSettings
+	rpcType    RPCType
+	fullMethod string
+	// startTime is only recorded for Panicked, other events are timed by
+	// the interceptors.
+	startTime time.Time
 }
 
-func newServerReporter(m *ServerMetrics, rpcType grpcType, fullMethod string) *serverReporter {
-	r := &serverReporter{
-		metrics: m,
-		rpcType: rpcType,
+func newServerReporter(m *ServerMetrics, rpcType RPCType, fullMethod string) *serverReporter {
+	cfg := m.config()
+	method := cfg.methods.get(rpcType, fullMethod, newServerMethodMetrics).(*serverMethodMetrics)
+	return &serverReporter{
+		metrics:    m,
+		cfg:        cfg,
+		method:     method,
+		settings:   method.settings.get(cfg.methodSettings, method.serviceName, fullMethod),
+		rpcType:    rpcType,
+		fullMethod: fullMethod,
 	}
-	if r.metrics.serverHandledHistogramEnabled {
+}
+
+func (r *serverReporter) Started(context.Context) {
+	if r.cfg.panicHandlingEnabled && (r.cfg.handledHistogramEnabled || r.cfg.sloEventsEnabled) {
 		r.startTime = time.Now()
 	}
-	r.serviceName, r.methodName = splitMethodName(fullMethod)
-	r.metrics.serverStartedCounter.WithLabelValues(string(r.rpcType), r.serviceName, r.methodName).Inc()
-	return r
+	r.method.started.get(r.metrics.serverStartedCounter, r.method.labels).Inc()
 }
 
 func (r *serverReporter) ReceivedMessage(ctx context.Context) {
-	r.metrics.serverStreamMsgReceivedCounter.WithLabelValues(append(
-		r.metrics.extension.ServerStreamMsgReceivedCounterValues(ctx),
-		string(r.rpcType), r.serviceName, r.methodName)...,
-	).Inc()
+	if r.metrics.extensionLabels {
+		r.metrics.serverStreamMsgReceivedCounter.WithLabelValues(append(
+			r.metrics.extension.ServerStreamMsgReceivedCounterValues(ctx),
+			r.method.labels...)...,
+		).Inc()
+		return
+	}
+	r.method.received.get(r.metrics.serverStreamMsgReceivedCounter, r.method.labels).Inc()
 }
 
 func (r *serverReporter) SentMessage(ctx context.Context) {
-	r.metrics.serverStreamMsgSentCounter.WithLabelValues(append(
-		r.metrics.extension.ServerStreamMsgSentCounterValues(ctx),
-		string(r.rpcType), r.serviceName, r.methodName)...,
-	).Inc()
+	if r.metrics.extensionLabels {
+		r.metrics.serverStreamMsgSentCounter.WithLabelValues(append(
+			r.metrics.extension.ServerStreamMsgSentCounterValues(ctx),
+			r.method.labels...)...,
+		).Inc()
+		return
+	}
+	r.method.sent.get(r.metrics.serverStreamMsgSentCounter, r.method.labels).Inc()
 }
 
-func (r *serverReporter) Handled(ctx context.Context, code codes.Code) {
-	r.metrics.serverHandledCounter.WithLabelValues(append(
-		r.metrics.extension.ServerHandledCounterValues(ctx),
-		string(r.rpcType), r.serviceName, r.methodName, code.String())...,
-	).Inc()
+func (r *serverReporter) Handled(ctx context.Context, code codes.Code, elapsed time.Duration) {
+	cached := int(code) < numCachedCodes
+	if r.metrics.extensionLabels || !cached {
+		r.metrics.serverHandledCounter.WithLabelValues(append(
+			r.metrics.extension.ServerHandledCounterValues(ctx),
+			string(r.rpcType), r.method.serviceName, r.method.methodName, code.String())...,
+		).Inc()
+	} else {
+		r.method.handled[code].getWith(r.metrics.serverHandledCounter, r.method.labels, code.String()).Inc()
+	}
+
+	if r.cfg.handledCodeClassEnabled {
+		if cached {
+			r.method.handledCodeClass[code].getWith(r.metrics.serverHandledCodeClassCounter, r.method.labels, codeClass(r.cfg.codeClasses, code)).Inc()
+		} else {
+			r.metrics.serverHandledCodeClassCounter.WithLabelValues(string(r.rpcType), r.method.serviceName, r.method.methodName, codeClass(r.cfg.codeClasses, code)).Inc()
+		}
+	}
+
+	if r.cfg.handledHistogramEnabled && !r.settings.NoHistograms {
+		if r.metrics.extensionLabels {
+			r.cfg.handledHistogram.forMethod(r.method.serviceName, r.fullMethod).WithLabelValues(append(
+				r.metrics.extension.ServerHandledHistogramValues(ctx),
+				r.method.labels...)...,
+			).Observe(elapsed.Seconds())
+		} else {
+			r.method.handledHistogram.get(r.cfg.handledHistogram, r.method.serviceName, r.fullMethod, r.method.labels).Observe(elapsed.Seconds())
+		}
+	}
+
+	if r.cfg.sloEventsEnabled {
+		result := sloResultGood
+		if codeClass(r.cfg.codeClasses, code) == CodeClassServerError {
+			result = sloResultError
+		} else if elapsed > r.cfg.latencyObjective(r.fullMethod) {
+			result = sloResultSlow
+		}
+		r.method.sloEvents[result].getWith(r.metrics.serverSLOEventsCounter, r.method.labels, allSLOResults[result]).Inc()
+	}
+}
 
-	if r.metrics.serverHandledHistogramEnabled {
-		r.metrics.serverHandledHistogram.WithLabelValues(append(
-			r.metrics.extension.ServerHandledHistogramValues(ctx),
-			string(r.rpcType), r.serviceName, r.methodName)...,
-		).Observe(time.Since(r.startTime).Seconds())
+// Panicked records a recovered panic and the RPC as handled with code.
+func (r *serverReporter) Panicked(ctx context.Context, code codes.Code) {
+	r.method.panics.get(r.metrics.serverPanicsCounter, r.method.labels).Inc()
+	var elapsed time.Duration
+	if !r.startTime.IsZero() {
+		elapsed = time.Since(r.startTime)
 	}
+	r.Handled(ctx, code, elapsed)
 }
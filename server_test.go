@@ -6,6 +6,7 @@ package grpc_prometheus
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -19,6 +20,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 
+	"github.com/golang/protobuf/proto"
 	pb_testproto "github.com/grpc-ecosystem/go-grpc-prometheus/examples/testproto"
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
@@ -59,6 +61,7 @@ func (s *ServerInterceptorTestSuite) SetupSuite() {
 	var err error
 
 	EnableHandlingTimeHistogram()
+	EnableHandledCodeClassCounter(nil)
 
 	s.serverListener, err = net.Listen("tcp", "127.0.0.1:0")
 	require.NoError(s.T(), err, "must be able to allocate a port for serverListener")
@@ -84,11 +87,7 @@ func (s *ServerInterceptorTestSuite) SetupTest() {
 	s.ctx, s.cancel = context.WithTimeout(context.TODO(), 2*time.Second)
 
 	// Make sure every test starts with same fresh, intialized metric state.
-	DefaultServerMetrics.serverStartedCounter.Reset()
-	DefaultServerMetrics.serverHandledCounter.Reset()
-	DefaultServerMetrics.serverHandledHistogram.Reset()
-	DefaultServerMetrics.serverStreamMsgReceivedCounter.Reset()
-	DefaultServerMetrics.serverStreamMsgSentCounter.Reset()
+	DefaultServerMetrics.Reset()
 	Register(s.server)
 }
 
@@ -124,6 +123,8 @@ func (s *ServerInterceptorTestSuite) TestRegisterPresetsStuff() {
 		{"grpc_server_handled_total", []string{"mwitkow.testproto.TestService", "PingList", "server_stream", "Aborted"}},
 		{"grpc_server_handled_total", []string{"mwitkow.testproto.TestService", "PingEmpty", "unary", "FailedPrecondition"}},
 		{"grpc_server_handled_total", []string{"mwitkow.testproto.TestService", "PingEmpty", "unary", "ResourceExhausted"}},
+		{"grpc_server_handled_code_class_total", []string{"mwitkow.testproto.TestService", "PingEmpty", "unary", "server_error"}},
+		{"grpc_server_handled_code_class_total", []string{"mwitkow.testproto.TestService", "PingList", "server_stream", "ok"}},
 	} {
 		lineCount := len(fetchPrometheusLines(s.T(), testCase.metricName, testCase.existingLabels...))
 		assert.NotEqual(s.T(), 0, lineCount, "metrics must exist for test case %d", testID)
@@ -135,13 +136,13 @@ func (s *ServerInterceptorTestSuite) TestUnaryIncrementsMetrics() {
 	require.NoError(s.T(), err)
 	requireValue(s.T(), 1, DefaultServerMetrics.serverStartedCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty"))
 	requireValue(s.T(), 1, DefaultServerMetrics.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty", "OK"))
-	requireValueHistCount(s.T(), 1, DefaultServerMetrics.serverHandledHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty"))
+	requireValueHistCount(s.T(), 1, DefaultServerMetrics.config().handledHistogram.defaultVec.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty"))
 
 	_, err = s.testClient.PingError(s.ctx, &pb_testproto.PingRequest{ErrorCodeReturned: uint32(codes.FailedPrecondition)}) // should return with code=FailedPrecondition
 	require.Error(s.T(), err)
 	requireValue(s.T(), 1, DefaultServerMetrics.serverStartedCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError"))
 	requireValue(s.T(), 1, DefaultServerMetrics.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError", "FailedPrecondition"))
-	requireValueHistCount(s.T(), 1, DefaultServerMetrics.serverHandledHistogram.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError"))
+	requireValueHistCount(s.T(), 1, DefaultServerMetrics.config().handledHistogram.defaultVec.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError"))
 }
 
 func (s *ServerInterceptorTestSuite) TestStartedStreamingIncrementsStarted() {
@@ -179,7 +180,7 @@ func (s *ServerInterceptorTestSuite) TestStreamingIncrementsMetrics() {
 	requireValueWithRetry(s.ctx, s.T(), 1,
 		DefaultServerMetrics.serverStreamMsgReceivedCounter.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList"))
 	requireValueWithRetryHistCount(s.ctx, s.T(), 1,
-		DefaultServerMetrics.serverHandledHistogram.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList"))
+		DefaultServerMetrics.config().handledHistogram.defaultVec.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList"))
 
 	_, err := s.testClient.PingList(s.ctx, &pb_testproto.PingRequest{ErrorCodeReturned: uint32(codes.FailedPrecondition)}) // should return with code=FailedPrecondition
 	require.NoError(s.T(), err, "PingList must not fail immediately")
@@ -189,7 +190,7 @@ func (s *ServerInterceptorTestSuite) TestStreamingIncrementsMetrics() {
 	requireValueWithRetry(s.ctx, s.T(), 1,
 		DefaultServerMetrics.serverHandledCounter.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList", "FailedPrecondition"))
 	requireValueWithRetryHistCount(s.ctx, s.T(), 2,
-		DefaultServerMetrics.serverHandledHistogram.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList"))
+		DefaultServerMetrics.config().handledHistogram.defaultVec.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList"))
 }
 
 // fetchPrometheusLines does mocked HTTP GET request against real prometheus handler to get the same view that Prometheus
@@ -257,6 +258,23 @@ func (s *testService) PingList(ping *pb_testproto.PingRequest, stream pb_testpro
 	return nil
 }
 
+func (s *testService) PingStream(stream pb_testproto.TestService_PingStreamServer) error {
+	count := 0
+	for {
+		ping, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&pb_testproto.PingResponse{Value: pingDefaultValue, Counter: int32(count)})
+		}
+		if err != nil {
+			return err
+		}
+		if ping.ErrorCodeReturned != 0 {
+			return status.Errorf(codes.Code(ping.ErrorCodeReturned), "foobar")
+		}
+		count++
+	}
+}
+
 // toFloat64HistCount does the same thing as prometheus go client testutil.ToFloat64, but for histograms.
 // TODO(bwplotka): Upstream this function to prometheus client.
 func toFloat64HistCount(h prometheus.Observer) uint64 {
@@ -352,3 +370,410 @@ func requireValueWithRetryHistCount(ctx context.Context, t *testing.T, expect in
 		}
 	}
 }
+
+func TestServerPanicHandling(t *testing.T) {
+	panickingUnary := func(context.Context, interface{}) (interface{}, error) { panic("boom") }
+	panickingStream := func(interface{}, grpc.ServerStream) error { panic("boom") }
+	unaryInfo := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingEmpty"}
+	streamInfo := &grpc.StreamServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingList", IsServerStream: true}
+
+	t.Run("recovered", func(t *testing.T) {
+		m := NewServerMetricsWithOptions(WithPanicHandling(func(p interface{}) error {
+			return status.Errorf(codes.Internal, "panic: %v", p)
+		}))
+
+		_, err := m.UnaryServerInterceptor()(context.TODO(), &pb_testproto.Empty{}, unaryInfo, panickingUnary)
+		require.Equal(t, codes.Internal, status.Code(err))
+		err = m.StreamServerInterceptor()(nil, &fakeServerStream{ctx: context.TODO()}, streamInfo, panickingStream)
+		require.Equal(t, codes.Internal, status.Code(err))
+
+		requireValue(t, 1, m.serverPanicsCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty"))
+		requireValue(t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty", "Internal"))
+		requireValue(t, 1, m.serverPanicsCounter.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList"))
+		requireValue(t, 1, m.serverHandledCounter.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList", "Internal"))
+	})
+
+	t.Run("recovered with the code of the recovery handler", func(t *testing.T) {
+		m := NewServerMetricsWithOptions(WithPanicHandling(func(p interface{}) error {
+			return status.Errorf(codes.Unavailable, "panic: %v", p)
+		}))
+
+		_, err := m.UnaryServerInterceptor()(context.TODO(), &pb_testproto.Empty{}, unaryInfo, panickingUnary)
+		require.Equal(t, codes.Unavailable, status.Code(err))
+
+		requireValue(t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty", "Unavailable"))
+		requireValue(t, 0, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty", "Internal"))
+	})
+
+	t.Run("recovered without error", func(t *testing.T) {
+		m := NewServerMetricsWithOptions(WithPanicHandling(func(interface{}) error { return nil }))
+
+		resp, err := m.UnaryServerInterceptor()(context.TODO(), &pb_testproto.Empty{}, unaryInfo, panickingUnary)
+		require.Nil(t, resp)
+		require.Equal(t, codes.Internal, status.Code(err), "the RPC must fail even if the recovery handler returns nil")
+		requireValue(t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty", "Internal"))
+	})
+
+	t.Run("repanicked", func(t *testing.T) {
+		m := NewServerMetricsWithOptions(WithPanicHandling(nil))
+
+		require.PanicsWithValue(t, "boom", func() {
+			m.UnaryServerInterceptor()(context.TODO(), &pb_testproto.Empty{}, unaryInfo, panickingUnary)
+		})
+		requireValue(t, 1, m.serverPanicsCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty"))
+		requireValue(t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty", "Internal"))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		m := NewServerMetrics()
+
+		require.PanicsWithValue(t, "boom", func() {
+			m.UnaryServerInterceptor()(context.TODO(), &pb_testproto.Empty{}, unaryInfo, panickingUnary)
+		})
+		requireValue(t, 0, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty", "Internal"))
+	})
+}
+
+func TestServerSLOEvents(t *testing.T) {
+	m := NewServerMetricsWithOptions(WithSLOEvents(time.Hour, map[string]time.Duration{
+		"/mwitkow.testproto.TestService/Ping": 0,
+	}))
+	interceptor := m.UnaryServerInterceptor()
+	okHandler := func(context.Context, interface{}) (interface{}, error) {
+		time.Sleep(time.Millisecond)
+		return &pb_testproto.Empty{}, nil
+	}
+	errHandler := func(_ context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Errorf(codes.Code(req.(*pb_testproto.PingRequest).ErrorCodeReturned), "Userspace error.")
+	}
+
+	interceptor(context.TODO(), &pb_testproto.Empty{}, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingEmpty"}, okHandler)
+	interceptor(context.TODO(), &pb_testproto.Empty{}, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"}, okHandler)
+	interceptor(context.TODO(), &pb_testproto.PingRequest{ErrorCodeReturned: uint32(codes.Unavailable)}, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingError"}, errHandler)
+	interceptor(context.TODO(), &pb_testproto.PingRequest{ErrorCodeReturned: uint32(codes.NotFound)}, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingError"}, errHandler)
+
+	requireValue(t, 1, m.serverSLOEventsCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty", "good"))
+	requireValue(t, 1, m.serverSLOEventsCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "slow"))
+	requireValue(t, 1, m.serverSLOEventsCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError", "error"))
+	requireValue(t, 1, m.serverSLOEventsCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError", "good"))
+}
+
+func TestServerHandledCodeClassCounter(t *testing.T) {
+	m := NewServerMetricsWithOptions(WithHandledCodeClassCounter(map[codes.Code]string{codes.Unimplemented: CodeClassClientError}))
+	interceptor := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingError"}
+	for _, code := range []codes.Code{codes.OK, codes.NotFound, codes.Unimplemented, codes.Internal, codes.Unavailable} {
+		interceptor(context.TODO(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+			return nil, status.Error(code, "Userspace error.")
+		})
+	}
+
+	requireValue(t, 1, m.serverHandledCodeClassCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError", "ok"))
+	requireValue(t, 2, m.serverHandledCodeClassCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError", "client_error"))
+	requireValue(t, 2, m.serverHandledCodeClassCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError", "server_error"))
+}
+
+var errTestNotFound = errors.New("not found")
+
+type testPermissionError struct{}
+
+func (testPermissionError) Error() string { return "permission denied" }
+
+func TestServerCodeResolvers(t *testing.T) {
+	m := NewServerMetricsWithOptions(WithCodeResolvers(
+		ResolveErrorIs(errTestNotFound, codes.NotFound),
+		ResolveErrorFunc(func(err error) bool {
+			var permErr testPermissionError
+			return errors.As(err, &permErr)
+		}, codes.PermissionDenied),
+	))
+	interceptor := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingError"}
+	for _, err := range []error{
+		fmt.Errorf("lookup: %w", errTestNotFound),
+		testPermissionError{},
+		status.Error(codes.FailedPrecondition, "Userspace error."),
+		errors.New("unmapped"),
+	} {
+		interceptor(context.TODO(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+			return nil, err
+		})
+	}
+
+	requireValue(t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError", "NotFound"))
+	requireValue(t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError", "PermissionDenied"))
+	requireValue(t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError", "FailedPrecondition"))
+	requireValue(t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingError", "Unknown"))
+}
+
+type histogramLabelExtension struct {
+	DefaultExtension
+}
+
+func (histogramLabelExtension) ServerHandledHistogramCustomLabels() []string {
+	return []string{"tenant"}
+}
+
+func (histogramLabelExtension) ServerHandledHistogramValues(context.Context) []string {
+	return []string{"acme"}
+}
+
+func TestServerMetricsOptions(t *testing.T) {
+	m := NewServerMetricsWithOptions(
+		WithExtension(histogramLabelExtension{}),
+		WithCounterOptions(WithConstLabels(prometheus.Labels{"app": "test"})),
+		WithHandlingTimeHistogram(WithHistogramBuckets([]float64{1, 2})),
+		WithHandledCodeClassCounter(nil),
+	)
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(m))
+
+	m.UnaryServerInterceptor()(context.TODO(), nil, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingEmpty"},
+		func(context.Context, interface{}) (interface{}, error) { return &pb_testproto.Empty{}, nil })
+
+	requireValue(t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty", "OK"))
+	requireValue(t, 1, m.serverHandledCodeClassCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty", "ok"))
+	requireValueHistCount(t, 1, m.config().handledHistogram.defaultVec.WithLabelValues("acme", "unary", "mwitkow.testproto.TestService", "PingEmpty"))
+
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	for _, mf := range mfs {
+		if mf.GetType() != dto.MetricType_COUNTER {
+			continue
+		}
+		for _, metric := range mf.GetMetric() {
+			require.Contains(t, metric.GetLabel(), &dto.LabelPair{Name: proto.String("app"), Value: proto.String("test")},
+				"counter options must apply to %s", mf.GetName())
+		}
+	}
+}
+
+func TestServerMetricsCounterOptions(t *testing.T) {
+	counterOpts := []CounterOption{func(o *prometheus.CounterOpts) { o.Namespace = "myapp" }}
+	for _, m := range []*ServerMetrics{
+		NewServerMetrics(counterOpts...),
+		NewServerMetrics(func(o *prometheus.CounterOpts) { o.Namespace = "myapp" }),
+	} {
+		reg := prometheus.NewPedanticRegistry()
+		m.MustRegister(reg)
+		m.UnaryServerInterceptor()(context.TODO(), nil, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingEmpty"},
+			func(context.Context, interface{}) (interface{}, error) { return &pb_testproto.Empty{}, nil })
+		require.Contains(t, gatherNames(t, reg), "myapp_grpc_server_started_total")
+	}
+}
+
+func TestServerMetricsConcurrentEnable(t *testing.T) {
+	m := NewServerMetrics()
+	interceptor := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingEmpty"}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			interceptor(context.TODO(), nil, info, func(context.Context, interface{}) (interface{}, error) { return nil, nil })
+		}
+	}()
+	m.EnableHandlingTimeHistogram()
+	m.EnableSLOEvents(time.Second, nil)
+	m.AddCodeResolvers(ResolveErrorIs(errTestNotFound, codes.NotFound))
+	m.Collect(make(chan prometheus.Metric, 1000))
+	<-done
+
+	requireValue(t, 100, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty", "OK"))
+}
+
+// fakeServerStream is a grpc.ServerStream for calling stream interceptors directly.
+type fakeServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeServerStream) SendMsg(interface{}) error {
+	return nil
+}
+
+func (s *fakeServerStream) RecvMsg(interface{}) error {
+	return nil
+}
+
+func BenchmarkServerUnaryInterceptor(b *testing.B) {
+	m := NewServerMetricsWithOptions(WithHandlingTimeHistogram(), WithHandledCodeClassCounter(nil))
+	interceptor := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"}
+	handler := func(context.Context, interface{}) (interface{}, error) { return nil, nil }
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			interceptor(ctx, nil, info, handler)
+		}
+	})
+}
+
+func BenchmarkServerStreamInterceptor(b *testing.B) {
+	m := NewServerMetricsWithOptions(WithHandlingTimeHistogram(), WithHandledCodeClassCounter(nil))
+	interceptor := m.StreamServerInterceptor()
+	info := &grpc.StreamServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingList", IsServerStream: true}
+	handler := func(_ interface{}, stream grpc.ServerStream) error {
+		stream.RecvMsg(nil)
+		for i := 0; i < 10; i++ {
+			stream.SendMsg(nil)
+		}
+		return nil
+	}
+	stream := &fakeServerStream{ctx: context.Background()}
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			interceptor(nil, stream, info, handler)
+		}
+	})
+}
+
+func TestServerHistogramBucketOverrides(t *testing.T) {
+	m := NewServerMetricsWithOptions(
+		WithHandlingTimeHistogram(WithHistogramBuckets([]float64{1})),
+		WithHistogramBucketOverrides(map[string][]float64{
+			"mwitkow.testproto.TestService":       {10, 30},
+			"/mwitkow.testproto.TestService/Ping": {0.001, 0.01},
+		}),
+	)
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(m))
+
+	interceptor := m.UnaryServerInterceptor()
+	handler := func(context.Context, interface{}) (interface{}, error) { return nil, nil }
+	for _, method := range []string{"/mwitkow.testproto.TestService/Ping", "/mwitkow.testproto.TestService/PingEmpty", "/other.Service/Ping"} {
+		interceptor(context.TODO(), nil, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	}
+
+	require.Equal(t, map[string][]float64{
+		"mwitkow.testproto.TestService/Ping":      {0.001, 0.01},
+		"mwitkow.testproto.TestService/PingEmpty": {10, 30},
+		"other.Service/Ping":                      {1},
+	}, gatherBuckets(t, reg, "grpc_server_handling_seconds"))
+}
+
+// gatherBuckets returns the upper bounds of the buckets of each series of the
+// named histogram, keyed by service and method.
+func gatherBuckets(t *testing.T, g prometheus.Gatherer, name string) map[string][]float64 {
+	mfs, err := g.Gather()
+	require.NoError(t, err)
+	buckets := map[string][]float64{}
+	for _, mf := range mfs {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, metric := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, lp := range metric.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			key := labels["grpc_service"] + "/" + labels["grpc_method"]
+			for _, b := range metric.GetHistogram().GetBucket() {
+				buckets[key] = append(buckets[key], b.GetUpperBound())
+			}
+		}
+	}
+	return buckets
+}
+
+func TestServerMetricsRegistration(t *testing.T) {
+	m := NewServerMetrics()
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, m.Register(reg))
+	require.Error(t, m.Register(reg), "registering twice must fail")
+
+	m.EnableHandlingTimeHistogram()
+	m.UnaryServerInterceptor()(context.TODO(), nil, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingEmpty"},
+		func(context.Context, interface{}) (interface{}, error) { return nil, nil })
+	require.Contains(t, gatherNames(t, reg), "grpc_server_handling_seconds", "histograms enabled after registration must be registered")
+
+	require.True(t, m.Unregister(reg))
+	require.Empty(t, gatherNames(t, reg))
+	require.NoError(t, m.Register(reg), "metrics must be registrable again after unregistering")
+}
+
+func TestServerMetricsReset(t *testing.T) {
+	m := NewServerMetricsWithOptions(WithHandlingTimeHistogram(), WithHandledCodeClassCounter(nil))
+	reg := prometheus.NewPedanticRegistry()
+	m.MustRegister(reg)
+	interceptor := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingEmpty"}
+	handler := func(context.Context, interface{}) (interface{}, error) { return nil, nil }
+	interceptor(context.TODO(), nil, info, handler)
+
+	m.Reset()
+	require.Empty(t, gatherNames(t, reg))
+
+	interceptor(context.TODO(), nil, info, handler)
+	requireValue(t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty", "OK"))
+	requireValueHistCount(t, 1, m.config().handledHistogram.defaultVec.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty"))
+}
+
+// gatherNames returns the names of the metric families with series in g.
+func gatherNames(t *testing.T, g prometheus.Gatherer) []string {
+	mfs, err := g.Gather()
+	require.NoError(t, err)
+	var names []string
+	for _, mf := range mfs {
+		names = append(names, mf.GetName())
+	}
+	return names
+}
+
+func TestServerHandlerLabels(t *testing.T) {
+	m := NewServerMetricsWithOptions(WithHandlerLabels("cache_hit", "shard"), WithHandlingTimeHistogram())
+	reg := prometheus.NewPedanticRegistry()
+	m.MustRegister(reg)
+
+	m.UnaryServerInterceptor()(context.TODO(), nil, &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"},
+		func(ctx context.Context, _ interface{}) (interface{}, error) {
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				SetLabel(ctx, "shard", "7")
+			}()
+			SetLabel(ctx, "cache_hit", "true")
+			SetLabel(ctx, "undeclared", "ignored")
+			<-done
+			return nil, nil
+		})
+	m.StreamServerInterceptor()(nil, &fakeServerStream{ctx: context.TODO()},
+		&grpc.StreamServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingList", IsServerStream: true},
+		func(_ interface{}, stream grpc.ServerStream) error {
+			SetLabel(stream.Context(), "cache_hit", "false")
+			return stream.SendMsg(nil)
+		})
+
+	requireValue(t, 1, m.serverHandledCounter.WithLabelValues("true", "7", "unary", "mwitkow.testproto.TestService", "Ping", "OK"))
+	requireValue(t, 1, m.serverHandledCounter.WithLabelValues("false", "", "server_stream", "mwitkow.testproto.TestService", "PingList", "OK"))
+	requireValueHistCount(t, 1, m.config().handledHistogram.defaultVec.WithLabelValues("true", "7", "unary", "mwitkow.testproto.TestService", "Ping"))
+	requireValue(t, 1, m.serverStreamMsgSentCounter.WithLabelValues("server_stream", "mwitkow.testproto.TestService", "PingList"))
+
+	SetLabel(context.TODO(), "cache_hit", "true") // must not panic outside of RPCs
+	_, err := reg.Gather()
+	require.NoError(t, err)
+}
+
+func TestServerExcludedMethods(t *testing.T) {
+	handler := func(context.Context, interface{}) (interface{}, error) { return nil, nil }
+	gather := &grpc.UnaryServerInfo{FullMethod: "/grpc_prometheus.metricsservice.MetricsService/Gather"}
+	ping := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"}
+
+	m := NewServerMetrics()
+	m.UnaryServerInterceptor()(context.TODO(), nil, gather, handler)
+	require.Equal(t, 0, countSeries(m.serverStartedCounter), "the metrics service must be excluded by default")
+
+	m = NewServerMetricsWithOptions(WithExcludedMethods("/mwitkow.testproto.TestService/Ping"))
+	m.UnaryServerInterceptor()(context.TODO(), nil, gather, handler)
+	m.UnaryServerInterceptor()(context.TODO(), nil, ping, handler)
+	requireValue(t, 1, m.serverStartedCounter.WithLabelValues("unary", "grpc_prometheus.metricsservice.MetricsService", "Gather"))
+	require.Equal(t, 1, countSeries(m.serverStartedCounter), "excluded methods must not be recorded")
+}
@@ -0,0 +1,130 @@
+package grpc_prometheus
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnknownHistogram is returned when enabling or disabling a histogram
+// the metrics don't have.
+var ErrUnknownHistogram = errors.New("grpc_prometheus: unknown histogram")
+
+// MethodSettings are the settings of the metrics of a service or method,
+// which can be changed at runtime.
+type MethodSettings struct {
+	// Excluded excludes the RPCs from all metrics.
+	Excluded bool `json:"excluded"`
+	// NoHistograms excludes the RPCs from the histograms only.
+	NoHistograms bool `json:"no_histograms"`
+}
+
+// Settings are the settings of ServerMetrics or ClientMetrics which can be
+// changed at runtime.
+type Settings struct {
+	// Histograms maps the names of the histograms to whether they are
+	// enabled.
+	Histograms map[string]bool `json:"histograms"`
+	// Methods maps services, e.g. "mwitkow.testproto.TestService", and full
+	// method names, e.g. "/mwitkow.testproto.TestService/Ping", to their
+	// settings. The settings of a full method name take precedence over
+	// those of its service.
+	Methods map[string]MethodSettings `json:"methods"`
+}
+
+// RuntimeSettings is implemented by ServerMetrics and ClientMetrics, whose
+// settings can be changed while RPCs are in flight, e.g. to enable
+// histograms during an incident. Metrics enabled at runtime are collected by
+// the registries the metrics were registered with, by Register or as a
+// single collector. A pedantic registry rejects them when the metrics are
+// registered as a single collector, unless WithUncheckedCollector is used.
+type RuntimeSettings interface {
+	// Settings returns the current settings.
+	Settings() Settings
+	// SetHistogramEnabled enables or disables the histogram with the given
+	// name. Disabling a histogram drops its observations. It returns an
+	// error wrapping ErrUnknownHistogram if there is no such histogram.
+	SetHistogramEnabled(name string, enabled bool) error
+	// SetMethodSettings sets the settings of a service or full method name.
+	SetMethodSettings(method string, settings MethodSettings)
+	// DeleteMethodSettings restores the default settings of a service or
+	// full method name.
+	DeleteMethodSettings(method string)
+}
+
+var (
+	_ RuntimeSettings = &ServerMetrics{}
+	_ RuntimeSettings = &ClientMetrics{}
+)
+
+func unknownHistogram(name string) error {
+	return fmt.Errorf("%w %q", ErrUnknownHistogram, name)
+}
+
+// defaultMethodSettings excludes the service of packages/metricsservice.
+func defaultMethodSettings() map[string]MethodSettings {
+	return map[string]MethodSettings{metricsServiceName: {Excluded: true}}
+}
+
+// resolveMethodSettings returns the settings of the method, falling back from
+// the full method name to the service name.
+func resolveMethodSettings(methods map[string]MethodSettings, serviceName, fullMethod string) MethodSettings {
+	if len(methods) == 0 {
+		return MethodSettings{}
+	}
+	if s, ok := methods[fullMethod]; ok {
+		return s
+	}
+	return methods[serviceName]
+}
+
+// withMethodSettings returns a copy of methods with the settings of method
+// set, or deleted if settings is nil.
+func withMethodSettings(methods map[string]MethodSettings, method string, settings *MethodSettings) map[string]MethodSettings {
+	updated := copyMethodSettings(methods)
+	if settings == nil {
+		delete(updated, method)
+	} else {
+		updated[method] = *settings
+	}
+	return updated
+}
+
+// withExcludedMethods returns a copy of methods excluding exactly the given
+// methods.
+func withExcludedMethods(methods map[string]MethodSettings, excluded []string) map[string]MethodSettings {
+	updated := make(map[string]MethodSettings, len(methods)+len(excluded))
+	for method, s := range methods {
+		s.Excluded = false
+		if s != (MethodSettings{}) {
+			updated[method] = s
+		}
+	}
+	for _, method := range excluded {
+		s := updated[method]
+		s.Excluded = true
+		updated[method] = s
+	}
+	return updated
+}
+
+func copyMethodSettings(methods map[string]MethodSettings) map[string]MethodSettings {
+	copied := make(map[string]MethodSettings, len(methods))
+	for method, s := range methods {
+		copied[method] = s
+	}
+	return copied
+}
+
+// cachedSettings memoizes the settings of a cached method. The settings of
+// a configuration never change, as its method cache is replaced along with
+// it.
+type cachedSettings struct {
+	once     sync.Once
+	settings MethodSettings
+}
+
+func (c *cachedSettings) get(methods map[string]MethodSettings, serviceName, fullMethod string) MethodSettings {
+	c.once.Do(func() { c.settings = resolveMethodSettings(methods, serviceName, fullMethod) })
+	return c.settings
+}
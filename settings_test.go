@@ -0,0 +1,117 @@
+package grpc_prometheus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestServerSetHistogramEnabled(t *testing.T) {
+	m := NewServerMetrics()
+	reg := prometheus.NewPedanticRegistry()
+	m.MustRegister(reg)
+	interceptor := m.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingEmpty"}
+	handler := func(context.Context, interface{}) (interface{}, error) { return nil, nil }
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			interceptor(context.TODO(), nil, info, handler)
+		}
+	}()
+	require.NoError(t, m.SetHistogramEnabled("grpc_server_handling_seconds", true))
+	wg.Wait()
+	require.True(t, m.Settings().Histograms["grpc_server_handling_seconds"])
+	interceptor(context.TODO(), nil, info, handler)
+	require.Contains(t, gatherNames(t, reg), "grpc_server_handling_seconds", "histograms enabled at runtime must be registered")
+
+	require.NoError(t, m.SetHistogramEnabled("grpc_server_handling_seconds", false))
+	interceptor(context.TODO(), nil, info, handler)
+	require.NotContains(t, gatherNames(t, reg), "grpc_server_handling_seconds", "histograms disabled at runtime must be unregistered")
+
+	err := m.SetHistogramEnabled("grpc_server_unknown_seconds", true)
+	require.True(t, errors.Is(err, ErrUnknownHistogram), "got %v", err)
+}
+
+func TestServerUncheckedCollector(t *testing.T) {
+	info := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingEmpty"}
+	handler := func(context.Context, interface{}) (interface{}, error) { return nil, nil }
+
+	checked := NewServerMetrics()
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(checked)
+	require.NoError(t, checked.SetHistogramEnabled("grpc_server_handling_seconds", true))
+	checked.UnaryServerInterceptor()(context.TODO(), nil, info, handler)
+	_, err := reg.Gather()
+	require.Error(t, err, "pedantic registries must reject undescribed histograms of checked collectors")
+
+	reg = prometheus.NewRegistry()
+	reg.MustRegister(checked)
+	require.Contains(t, gatherNames(t, reg), "grpc_server_handling_seconds", "other registries must collect undescribed histograms")
+
+	unchecked := NewServerMetricsWithOptions(WithUncheckedCollector())
+	reg = prometheus.NewPedanticRegistry()
+	reg.MustRegister(unchecked)
+	require.NoError(t, unchecked.SetHistogramEnabled("grpc_server_handling_seconds", true))
+	unchecked.UnaryServerInterceptor()(context.TODO(), nil, info, handler)
+	require.Contains(t, gatherNames(t, reg), "grpc_server_handling_seconds")
+}
+
+func TestServerMethodSettings(t *testing.T) {
+	m := NewServerMetricsWithOptions(WithHandlingTimeHistogram())
+	interceptor := m.UnaryServerInterceptor()
+	handler := func(context.Context, interface{}) (interface{}, error) { return nil, nil }
+	ping := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/Ping"}
+	pingEmpty := &grpc.UnaryServerInfo{FullMethod: "/mwitkow.testproto.TestService/PingEmpty"}
+
+	m.SetMethodSettings("mwitkow.testproto.TestService", MethodSettings{NoHistograms: true})
+	m.SetMethodSettings("/mwitkow.testproto.TestService/PingEmpty", MethodSettings{Excluded: true})
+	interceptor(context.TODO(), nil, ping, handler)
+	interceptor(context.TODO(), nil, pingEmpty, handler)
+	requireValue(t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping", "OK"))
+	requireValue(t, 0, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty", "OK"))
+	require.Equal(t, 0, countSeries(m.config().handledHistogram), "methods without histograms must not be observed")
+	require.Equal(t, map[string]MethodSettings{
+		"grpc_prometheus.metricsservice.MetricsService": {Excluded: true},
+		"mwitkow.testproto.TestService":                 {NoHistograms: true},
+		"/mwitkow.testproto.TestService/PingEmpty":      {Excluded: true},
+	}, m.Settings().Methods)
+
+	m.DeleteMethodSettings("mwitkow.testproto.TestService")
+	m.DeleteMethodSettings("/mwitkow.testproto.TestService/PingEmpty")
+	interceptor(context.TODO(), nil, ping, handler)
+	interceptor(context.TODO(), nil, pingEmpty, handler)
+	requireValue(t, 1, m.serverHandledCounter.WithLabelValues("unary", "mwitkow.testproto.TestService", "PingEmpty", "OK"))
+	requireValueHistCount(t, 1, m.config().handledHistogram.defaultVec.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+}
+
+func TestClientRuntimeSettings(t *testing.T) {
+	m := NewClientMetrics()
+	interceptor := m.UnaryClientInterceptor()
+	invoker := func(context.Context, string, interface{}, interface{}, *grpc.ClientConn, ...grpc.CallOption) error {
+		return nil
+	}
+
+	require.Equal(t, map[string]bool{
+		"grpc_client_handling_seconds":          false,
+		"grpc_client_msg_recv_handling_seconds": false,
+		"grpc_client_msg_send_handling_seconds": false,
+	}, m.Settings().Histograms)
+	require.NoError(t, m.SetHistogramEnabled("grpc_client_handling_seconds", true))
+	m.SetMethodSettings("/mwitkow.testproto.TestService/PingError", MethodSettings{Excluded: true})
+	interceptor(context.TODO(), "/mwitkow.testproto.TestService/Ping", nil, nil, nil, invoker)
+	interceptor(context.TODO(), "/mwitkow.testproto.TestService/PingError", nil, nil, nil, invoker)
+
+	requireValueHistCount(t, 1, m.config().handledHistogram.defaultVec.WithLabelValues("unary", "mwitkow.testproto.TestService", "Ping"))
+	require.Equal(t, 1, countSeries(m.clientStartedCounter), "excluded methods must not be recorded")
+	err := m.SetHistogramEnabled("grpc_server_handling_seconds", true)
+	require.True(t, errors.Is(err, ErrUnknownHistogram), "got %v", err)
+}
@@ -4,19 +4,21 @@
 package grpc_prometheus
 
 import (
+	"context"
 	"strings"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 )
 
-type grpcType string
+// RPCType is the type of an RPC, the value of the grpc_type label.
+type RPCType string
 
 const (
-	Unary        grpcType = "unary"
-	ClientStream grpcType = "client_stream"
-	ServerStream grpcType = "server_stream"
-	BidiStream   grpcType = "bidi_stream"
+	Unary        RPCType = "unary"
+	ClientStream RPCType = "client_stream"
+	ServerStream RPCType = "server_stream"
+	BidiStream   RPCType = "bidi_stream"
 )
 
 var (
@@ -28,7 +30,76 @@ var (
 	}
 )
 
-func splitMethodName(fullMethodName string) (string, string) {
+// Results of RPCs measured against their latency objective, indexing
+// allSLOResults.
+const (
+	sloResultGood = iota
+	sloResultSlow
+	sloResultError
+)
+
+var allSLOResults = [...]string{
+	sloResultGood:  "good",
+	sloResultSlow:  "slow",
+	sloResultError: "error",
+}
+
+// Classes of gRPC codes used as values of the grpc_code_class label.
+const (
+	CodeClassOK          = "ok"
+	CodeClassClientError = "client_error"
+	CodeClassServerError = "server_error"
+)
+
+var allCodeClasses = []string{CodeClassOK, CodeClassClientError, CodeClassServerError}
+
+// DefaultCodeClasses returns the default class of every gRPC code. Codes
+// signalling a problem with the request are client errors, codes signalling
+// a failure on the serving side are server errors.
+func DefaultCodeClasses() map[codes.Code]string {
+	classes := make(map[codes.Code]string, len(allCodes))
+	for _, code := range allCodes {
+		switch code {
+		case codes.OK:
+			classes[code] = CodeClassOK
+		case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal, codes.Unavailable, codes.DataLoss:
+			classes[code] = CodeClassServerError
+		default:
+			classes[code] = CodeClassClientError
+		}
+	}
+	return classes
+}
+
+// mergeCodeClasses returns the default code classes with the overrides
+// applied.
+func mergeCodeClasses(overrides map[codes.Code]string) map[codes.Code]string {
+	classes := DefaultCodeClasses()
+	for code, class := range overrides {
+		classes[code] = class
+	}
+	return classes
+}
+
+// codeClass returns the class of the code, codes missing from the classes
+// are considered server errors.
+func codeClass(classes map[codes.Code]string, code codes.Code) string {
+	if class, ok := classes[code]; ok {
+		return class
+	}
+	return CodeClassServerError
+}
+
+// metricsServiceName is the name of the service of packages/metricsservice,
+// whose RPCs are excluded from the metrics by default so that scrapes don't
+// show up as traffic.
+const metricsServiceName = "grpc_prometheus.metricsservice.MetricsService"
+
+// SplitMethodName splits a full method name, e.g.
+// "/mwitkow.testproto.TestService/Ping", into the service and method names
+// used as the grpc_service and grpc_method labels. Both are "unknown" if the
+// name isn't a full method name.
+func SplitMethodName(fullMethodName string) (service, method string) {
 	fullMethodName = strings.TrimPrefix(fullMethodName, "/") // remove leading slash
 	if i := strings.Index(fullMethodName, "/"); i >= 0 {
 		return fullMethodName[:i], fullMethodName[i+1:]
@@ -36,7 +107,7 @@ func splitMethodName(fullMethodName string) (string, string) {
 	return "unknown", "unknown"
 }
 
-func typeFromMethodInfo(mInfo *grpc.MethodInfo) grpcType {
+func typeFromMethodInfo(mInfo *grpc.MethodInfo) RPCType {
 	if !mInfo.IsClientStream && !mInfo.IsServerStream {
 		return Unary
 	}
@@ -48,3 +119,15 @@ func typeFromMethodInfo(mInfo *grpc.MethodInfo) grpcType {
 	}
 	return BidiStream
 }
+
+// codeFromContextError returns the gRPC code matching the error of a done
+// context.
+func codeFromContextError(err error) codes.Code {
+	switch err {
+	case context.DeadlineExceeded:
+		return codes.DeadlineExceeded
+	case context.Canceled:
+		return codes.Canceled
+	}
+	return codes.Unknown
+}